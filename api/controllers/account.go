@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/accounts"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/mailer"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+)
+
+// Request Export
+// The archive is built in the background, the user gets an email once it is ready
+func RequestExport(c *gin.Context) {
+	var userInput struct {
+		Format string `json:"format" binding:"omitempty,oneof=json zip"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+	if userInput.Format == "" {
+		userInput.Format = accounts.FormatJSON
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	// Exports lost by a restart are marked failed by the scheduler
+	var pending int64
+	initializers.DB.Model(&models.DataExport{}).
+		Where("user_id = ? AND status IN ?", user.ID, []string{models.ExportPending, models.ExportProcessing}).
+		Count(&pending)
+	if pending > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "An export is already being prepared",
+		})
+		return
+	}
+
+	// The scheduler picks the export up
+	export := models.DataExport{
+		UserID: user.ID,
+		Format: userInput.Format,
+		Status: models.ExportPending,
+	}
+	if err := initializers.DB.Create(&export).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"export": export,
+	})
+}
+
+// Get Exports
+func GetExports(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var exports []models.DataExport
+	if err := initializers.DB.Where("user_id = ?", user.ID).Order("id desc").Find(&exports).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exports": exports,
+	})
+}
+
+// Download Export
+func DownloadExport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var export models.DataExport
+	if err := initializers.DB.Where("user_id = ?", user.ID).First(&export, c.Param("id")).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if export.Status != models.ExportReady {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The export is not ready",
+		})
+		return
+	}
+
+	// The purge job deletes expired archives only now and then
+	if export.ExpiresAt != nil && export.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "The export has expired",
+		})
+		return
+	}
+
+	c.FileAttachment(export.FilePath, fmt.Sprintf("blog-export-%s.%s", export.CreatedAt.Format("2006-01-02"), export.Format))
+}
+
+// Request Deletion
+// The account and its posts are deleted permanently after the cooling-off
+// period, until then the user can cancel
+func RequestDeletion(c *gin.Context) {
+	var userInput struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !confirmIdentity(c, &user, userInput.Password, userInput.Code) {
+		return
+	}
+
+	if user.DeletionScheduledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The deletion of your account is already scheduled",
+		})
+		return
+	}
+
+	if err := accounts.ScheduleDeletion(&user, time.Now()); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body:    fmt.Sprintf("Hi %s,\n\nYour account and your posts will be deleted permanently on %s.\nLogin and cancel the deletion before then to keep them.", user.Name, user.DeletionScheduledAt.Format(time.RFC1123)),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":               "Your account will be deleted permanently",
+		"deletion_scheduled_at": user.DeletionScheduledAt,
+	})
+}
+
+// Cancel Deletion
+func CancelDeletion(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.DeletionScheduledAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "The deletion of your account is not scheduled",
+		})
+		return
+	}
+
+	if err := accounts.CancelDeletion(&user); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The deletion of your account has been cancelled",
+	})
+}
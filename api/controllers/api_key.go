@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/apikeys"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/helpers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+)
+
+// Get API Keys of the current user
+func GetAPIKeys(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var keys []models.APIKey
+	if err := initializers.DB.Where("user_id = ?", authUser.ID).Order("id").Find(&keys).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_keys": keys,
+	})
+}
+
+// Create API Key
+// The key is only returned in this response, we only keep its hash
+func CreateAPIKey(c *gin.Context) {
+	var userInput struct {
+		Name          string   `json:"name" binding:"required,min=2,max=100"`
+		Scopes        []string `json:"scopes" binding:"required,min=1"`
+		ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	for _, scope := range userInput.Scopes {
+		if !apikeys.IsValidScope(scope) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": map[string]interface{}{
+					"Scopes": "Unknown scope " + scope + ", valid scopes are " + strings.Join(apikeys.Scopes, ", "),
+				},
+			})
+			return
+		}
+	}
+
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	raw, prefix, err := apikeys.Generate()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if userInput.ExpiresInDays == 0 {
+		userInput.ExpiresInDays = 90
+	}
+	expiresAt := time.Now().AddDate(0, 0, userInput.ExpiresInDays)
+
+	key := models.APIKey{
+		UserID:    authUser.ID,
+		Name:      userInput.Name,
+		Prefix:    prefix,
+		KeyHash:   tokens.HashToken(raw),
+		Scopes:    userInput.Scopes,
+		ExpiresAt: &expiresAt,
+	}
+	if err := initializers.DB.Create(&key).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_key": key,
+		"key":     raw,
+		"message": "Copy the key now, it won't be shown again",
+	})
+}
+
+// Rename API Key
+func UpdateAPIKey(c *gin.Context) {
+	var userInput struct {
+		Name string `json:"name" binding:"required,min=2,max=100"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	key, ok := findOwnAPIKey(c)
+	if !ok {
+		return
+	}
+
+	if err := initializers.DB.Model(&key).Update("name", userInput.Name).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_key": key,
+	})
+}
+
+// Revoke API Key
+func RevokeAPIKey(c *gin.Context) {
+	key, ok := findOwnAPIKey(c)
+	if !ok {
+		return
+	}
+
+	if key.RevokedAt == nil {
+		if err := initializers.DB.Model(&key).Update("revoked_at", time.Now()).Error; err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The API key has been revoked",
+	})
+}
+
+func findOwnAPIKey(c *gin.Context) (models.APIKey, bool) {
+	var key models.APIKey
+
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return key, false
+	}
+
+	err := initializers.DB.Where("id = ? AND user_id = ?", c.Param("id"), authUser.ID).First(&key).Error
+	if err != nil {
+		format_errors.RecordNotFound(c, err)
+		return key, false
+	}
+	return key, true
+}
@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/helpers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/pagination"
+	"github.com/wisnuuakbr/blog-rest-go/internal/policies"
+	"gorm.io/gorm"
+)
+
+// Get Comments
+// ?view=tree (default) paginates the top-level comments with their replies,
+// ?view=flat paginates every comment from the oldest
+func GetComments(c *gin.Context) {
+	post, ok := commentedPost(c)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page parameter"})
+		return
+	}
+
+	perPage, err := strconv.Atoi(c.DefaultQuery("perPage", "20"))
+	if err != nil || perPage < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid perPage parameter"})
+		return
+	}
+
+	preloadUser := func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name")
+	}
+
+	var comments []models.Comment
+	var result pagination.PaginateResult
+
+	switch c.DefaultQuery("view", "tree") {
+	case "flat":
+		result, err = pagination.Paginate(initializers.DB, page, perPage, func(query *gorm.DB) *gorm.DB {
+			return query.Where("post_id = ?", post.ID).Order("created_at, id").Preload("User", preloadUser)
+		}, &comments)
+
+	case "tree":
+		// Deleted top-level comments are kept while they have replies
+		result, err = pagination.Paginate(initializers.DB.Unscoped(), page, perPage, func(query *gorm.DB) *gorm.DB {
+			return query.Where("comments.post_id = ? AND comments.parent_id IS NULL", post.ID).
+				Where("comments.deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments replies WHERE replies.root_id = comments.id AND replies.deleted_at IS NULL)").
+				Order("comments.created_at, comments.id").
+				Preload("User", preloadUser)
+		}, &comments)
+		if err == nil {
+			err = loadReplies(comments, preloadUser)
+		}
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid view parameter"})
+		return
+	}
+
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response":        result,
+		"comments_locked": post.CommentsLocked,
+	})
+}
+
+// Create Comment
+// Send parent_id to reply to a comment, up to COMMENT_MAX_DEPTH levels
+func CreateComment(c *gin.Context) {
+	var userInput struct {
+		Body     string `json:"body" binding:"required,max=5000"`
+		ParentID *uint  `json:"parent_id"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	post, ok := commentedPost(c)
+	if !ok {
+		return
+	}
+
+	if post.Status != models.PostPublished {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Only published posts can be commented",
+		})
+		return
+	}
+
+	authUser := helpers.GetAuthUser(c)
+	if !policies.CanComment(authUser, &post) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "The comments of this post are locked",
+		})
+		return
+	}
+
+	comment := models.Comment{
+		PostID: post.ID,
+		UserID: &authUser.ID,
+		Body:   userInput.Body,
+	}
+
+	if userInput.ParentID != nil {
+		var parent models.Comment
+		if err := initializers.DB.Where("post_id = ?", post.ID).First(&parent, *userInput.ParentID).Error; err != nil {
+			format_errors.RecordNotFound(c, err, "The parent comment not found")
+			return
+		}
+
+		maxDepth := config.GetInt("COMMENT_MAX_DEPTH", 3)
+		if parent.Depth+1 > maxDepth {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": map[string]interface{}{
+					"ParentID": fmt.Sprintf("Replies can't be nested deeper than %d levels", maxDepth),
+				},
+			})
+			return
+		}
+
+		comment.ParentID = &parent.ID
+		comment.Depth = parent.Depth + 1
+		comment.RootID = parent.RootID
+		if comment.RootID == nil {
+			comment.RootID = &parent.ID
+		}
+	}
+
+	if err := initializers.DB.Create(&comment).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comment": comment,
+	})
+}
+
+// Update Comment
+// Only the author can edit a comment, within COMMENT_EDIT_WINDOW of posting it
+func UpdateComment(c *gin.Context) {
+	var userInput struct {
+		Body string `json:"body" binding:"required,max=5000"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	var comment models.Comment
+	if err := initializers.DB.First(&comment, c.Param("id")).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	authUser := helpers.GetAuthUser(c)
+	if !policies.CanEditComment(authUser, &comment) {
+		format_errors.Forbidden(c)
+		return
+	}
+
+	editWindow := config.GetDuration("COMMENT_EDIT_WINDOW", 15*time.Minute)
+	if time.Since(comment.CreatedAt) > editWindow {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": fmt.Sprintf("Comments can only be edited for %s", editWindow),
+		})
+		return
+	}
+
+	var post models.Post
+	if err := initializers.DB.First(&post, comment.PostID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+	if post.Status != models.PostPublished {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Only the comments of published posts can be edited",
+		})
+		return
+	}
+	if !policies.CanComment(authUser, &post) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "The comments of this post are locked",
+		})
+		return
+	}
+
+	now := time.Now()
+	err := initializers.DB.Model(&comment).Updates(map[string]interface{}{
+		"body":      userInput.Body,
+		"edited_at": now,
+	}).Error
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+	comment.Body = userInput.Body
+	comment.EditedAt = &now
+
+	c.JSON(http.StatusOK, gin.H{
+		"comment": comment,
+	})
+}
+
+// Delete Comment
+// The replies stay, the comment shows in the tree without its body
+func DeleteComment(c *gin.Context) {
+	var comment models.Comment
+	if err := initializers.DB.First(&comment, c.Param("id")).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	var post models.Post
+	if err := initializers.DB.Unscoped().First(&post, comment.PostID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if !policies.CanDeleteComment(helpers.GetAuthUser(c), &comment, &post) {
+		format_errors.Forbidden(c)
+		return
+	}
+
+	if err := initializers.DB.Delete(&comment).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The comment has been deleted successfully",
+	})
+}
+
+// Lock Comments
+func LockComments(c *gin.Context) {
+	setCommentsLocked(c, true, "The comments have been locked")
+}
+
+// Unlock Comments
+func UnlockComments(c *gin.Context) {
+	setCommentsLocked(c, false, "The comments have been unlocked")
+}
+
+func setCommentsLocked(c *gin.Context, locked bool, message string) {
+	var post models.Post
+	if err := initializers.DB.First(&post, c.Param("id")).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// Only the author or an editor can lock the comments
+	if !policies.CanUpdatePost(helpers.GetAuthUser(c), &post) {
+		format_errors.Forbidden(c)
+		return
+	}
+
+	if err := initializers.DB.Model(&post).Update("comments_locked", locked).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
+
+// commentedPost finds the post of the route, drafts of other users are not found
+func commentedPost(c *gin.Context) (models.Post, bool) {
+	var post models.Post
+	err := initializers.DB.Scopes(policies.VisiblePosts(helpers.GetAuthUser(c))).First(&post, c.Param("id")).Error
+	if err != nil {
+		format_errors.RecordNotFound(c, err)
+		return post, false
+	}
+	return post, true
+}
+
+// loadReplies fills the replies of the top-level comments, deleted replies
+// are only kept when they have replies themselves
+func loadReplies(roots []models.Comment, preloadUser func(*gorm.DB) *gorm.DB) error {
+	if len(roots) == 0 {
+		return nil
+	}
+
+	rootIDs := make([]uint, len(roots))
+	for i, root := range roots {
+		rootIDs[i] = root.ID
+	}
+
+	var replies []models.Comment
+	err := initializers.DB.Unscoped().
+		Where("root_id IN ?", rootIDs).
+		Order("created_at, id").
+		Preload("User", preloadUser).
+		Find(&replies).Error
+	if err != nil {
+		return err
+	}
+
+	children := map[uint][]*models.Comment{}
+	for i := range replies {
+		reply := &replies[i]
+		children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+	}
+
+	for i := range roots {
+		buildThread(&roots[i], children)
+	}
+	return nil
+}
+
+// buildThread attaches the replies to the comment, it reports if the comment
+// is worth showing
+func buildThread(comment *models.Comment, children map[uint][]*models.Comment) bool {
+	for _, child := range children[comment.ID] {
+		if buildThread(child, children) {
+			comment.Replies = append(comment.Replies, *child)
+		}
+	}
+
+	if comment.DeletedAt.Valid {
+		comment.Body = ""
+		comment.UserID = nil
+		comment.User = nil
+		return len(comment.Replies) > 0
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/helpers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/passwords"
+	"github.com/wisnuuakbr/blog-rest-go/internal/validations"
+	"gorm.io/gorm"
+)
+
+// currentUser loads the authenticated user from the database
+func currentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return user, false
+	}
+
+	if err := initializers.DB.First(&user, authUser.ID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return user, false
+	}
+	return user, true
+}
+
+// confirmIdentity checks the password before a sensitive change and writes
+// the error. Users who only login with a provider have no password, they
+// confirm with a code of their authenticator or by having just logged in.
+// Failures count towards the login throttle of the user's email.
+func confirmIdentity(c *gin.Context, user *models.User, password, code string) bool {
+	if (user.Password != "" || code != "") && loginLocked(c, user.Email) {
+		return false
+	}
+
+	if user.Password != "" {
+		if valid, err := passwords.Verify(password, user.Password); err != nil || !valid {
+			recordLoginFailure(c, user.Email)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid password",
+			})
+			return false
+		}
+		return true
+	}
+
+	if code != "" && user.TOTPEnabledAt != nil {
+		valid, err := verifySecondFactor(user, code, "")
+		if err != nil {
+			format_errors.InternalServerError(c)
+			return false
+		}
+		if !valid {
+			recordLoginFailure(c, user.Email)
+			invalidSecondFactor(c)
+			return false
+		}
+		return true
+	}
+
+	session := helpers.GetAuthSession(c)
+	if session == nil || time.Since(session.CreatedAt) > config.GetDuration("REAUTH_WINDOW", 10*time.Minute) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Login again with your provider to confirm this change",
+		})
+		return false
+	}
+	return true
+}
+
+// bindInput binds the JSON body and writes the validation errors
+func bindInput(c *gin.Context, userInput interface{}) bool {
+	if err := c.ShouldBindJSON(userInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// defaultRoleID returns the role of new users, nil when the roles are not seeded
+func defaultRoleID(db *gorm.DB) *uint {
+	var role models.Role
+	if err := db.Where("name = ?", models.RoleAuthor).First(&role).Error; err != nil {
+		return nil
+	}
+	return &role.ID
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/jwks"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+)
+
+// JWKS
+// Publishes the public keys tokens can be verified with, retired keys
+// stay listed until their grace period ends
+func JWKS(c *gin.Context) {
+	keys, err := tokens.Keys()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	set := jwks.Set{Keys: []jwks.Key{}}
+	for _, key := range keys.PublicKeys(time.Now()) {
+		jwk, err := jwks.FromPublicKey(key.ID, key.Method.Alg(), key.Public)
+		if err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/helpers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/mailer"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/passwords"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+	"github.com/wisnuuakbr/blog-rest-go/internal/validations"
+)
+
+// Get Me
+// Returns the profile of the current user
+func GetMe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// Change Password
+// Needs the current password, every other session is logged out. Users
+// without a password set their first one.
+func ChangePassword(c *gin.Context) {
+	var userInput struct {
+		CurrentPassword string `json:"current_password"`
+		Code            string `json:"code"`
+		Password        string `json:"password" binding:"required"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !confirmIdentity(c, &user, userInput.CurrentPassword, userInput.Code) {
+		return
+	}
+
+	// Password policy validation
+	if errs := passwords.DefaultPolicy().Validate("Password", userInput.Password, user.Name, user.Email); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": errs,
+		})
+		return
+	}
+
+	hashPassword, err := passwords.Hash(userInput.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to hash password",
+		})
+		return
+	}
+
+	if err := initializers.DB.Model(&user).Update("password", hashPassword).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	var currentID uint
+	if current := helpers.GetAuthSession(c); current != nil {
+		currentID = current.ID
+	}
+	if err := tokens.RevokeOtherSessions(user.ID, currentID); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Your password has been changed, your other sessions have been logged out",
+	})
+}
+
+// Change Email
+// Sends a confirmation link to the new email, the email only changes once
+// the link is opened
+func ChangeEmail(c *gin.Context) {
+	var userInput struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !confirmIdentity(c, &user, userInput.Password, userInput.Code) {
+		return
+	}
+
+	if userInput.Email == user.Email {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Email": "The email is the same as the current one",
+			},
+		})
+		return
+	}
+
+	// Email validation
+	if validations.IsUniqueValue("users", "email", userInput.Email) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Email": "The email is already exist!",
+			},
+		})
+		return
+	}
+
+	if err := sendEmailChangeEmail(&user, userInput.Email); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "A confirmation link has been sent to " + userInput.Email,
+	})
+}
+
+// Confirm Email Change
+func ConfirmEmailChange(c *gin.Context) {
+	userID, email, newEmail, err := tokens.ParseEmailChangeToken(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "The confirmation link is invalid or has expired",
+		})
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// The email changed since the link was sent
+	if user.Email != email {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "The confirmation link is invalid or has expired",
+		})
+		return
+	}
+
+	// Someone registered with the email in the meantime
+	if validations.IsUniqueValue("users", "email", newEmail) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The email is already exist!",
+		})
+		return
+	}
+
+	// Opening the link proves the new email belongs to the user
+	err = initializers.DB.Model(&user).Updates(map[string]interface{}{
+		"email":             newEmail,
+		"email_verified_at": time.Now(),
+	}).Error
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// Let the old address know, in case the account was taken over
+	mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Your email has been changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nThe email of your account has been changed to %s.\nIf you didn't do this, please reset your password.", user.Name, newEmail),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Your email has been changed to " + newEmail,
+	})
+}
+
+func sendEmailChangeEmail(user *models.User, newEmail string) error {
+	token, err := tokens.NewEmailChangeToken(user.ID, user.Email, newEmail)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/email/confirm-change?token=%s", config.GetEnv("APP_URL", "http://localhost:3000"), url.QueryEscape(token))
+	return mailer.Send(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email",
+		Body:    fmt.Sprintf("Hi %s,\n\nPlease confirm your new email by opening the link below.\n\n%s", user.Name, link),
+	})
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/oidc"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+	"gorm.io/gorm"
+)
+
+const oidcStateCookie = "OIDCState"
+
+// OIDC Login
+// Redirects to the identity provider, add ?return_token=true to get the
+// tokens in the callback body instead of cookies
+func OIDCLogin(c *gin.Context) {
+	provider, err := oidc.Default()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Social login is not enabled",
+		})
+		return
+	}
+
+	state, err1 := oidc.RandomString(16)
+	nonce, err2 := oidc.RandomString(16)
+	codeVerifier, err3 := oidc.NewCodeVerifier()
+	if err := errors.Join(err1, err2, err3); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	cookie, err := tokens.NewOIDCState(tokens.OIDCState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ReturnToken:  c.Query("return_token") == "true",
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "The identity provider is not available",
+		})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, cookie, int((10 * time.Minute).Seconds()), "", "", false, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDC Callback
+// Links the external identity to a user by verified email, or creates the user
+func OIDCCallback(c *gin.Context) {
+	provider, err := oidc.Default()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Social login is not enabled",
+		})
+		return
+	}
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "The identity provider refused the login: " + errorCode,
+		})
+		return
+	}
+
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "", "", false, true)
+
+	state, err := tokens.ParseOIDCState(cookie)
+	if err != nil || c.Query("state") != state.State || c.Query("code") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid login state, please try again",
+		})
+		return
+	}
+
+	token, err := provider.Exchange(c.Request.Context(), c.Query("code"), state.CodeVerifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "Failed to exchange the authorization code",
+		})
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(c.Request.Context(), token.IDToken, state.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid ID token",
+		})
+		return
+	}
+
+	user, err := findOrCreateExternalUser(provider.Name, claims)
+	if errors.Is(err, errEmailNotVerified) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "The identity provider did not verify your email",
+		})
+		return
+	}
+	if errors.Is(err, errAccountDeleted) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This account has been deleted",
+		})
+		return
+	}
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	completeLogin(c, user, state.ReturnToken)
+}
+
+var (
+	errEmailNotVerified = errors.New("email not verified")
+	errAccountDeleted   = errors.New("account deleted")
+)
+
+func findOrCreateExternalUser(providerName string, claims *oidc.Claims) (*models.User, error) {
+	var user models.User
+
+	// Already linked
+	var identity models.ExternalIdentity
+	err := initializers.DB.Preload("User").
+		Where("provider = ? AND subject = ?", providerName, claims.Subject).
+		First(&identity).Error
+	if err == nil && identity.User.ID != 0 {
+		return &identity.User, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Linking by email is only safe when the provider verified it
+	if !claims.EmailVerified || claims.Email == "" {
+		return nil, errEmailNotVerified
+	}
+
+	takenOver := false
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Trashed users still own their email
+		err := tx.Unscoped().Where("email = ?", claims.Email).First(&user).Error
+		if err == nil && user.DeletedAt.Valid {
+			return errAccountDeleted
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			name := claims.Name
+			if name == "" {
+				name = claims.Email
+			}
+
+			verifiedAt := time.Now()
+			user = models.User{
+				Name:            name,
+				Email:           claims.Email,
+				EmailVerifiedAt: &verifiedAt,
+				RoleID:          defaultRoleID(tx),
+			}
+			err = tx.Create(&user).Error
+		} else if err == nil && user.EmailVerifiedAt == nil {
+			// The provider proved the user owns the email, whoever registered
+			// it before may not be the user
+			takenOver = true
+			err = resetUnverifiedAccount(tx, &user)
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&models.ExternalIdentity{
+			UserID:   user.ID,
+			Provider: providerName,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Log out whoever used the account before
+	if takenOver {
+		if err := tokens.RevokeAllForUser(user.ID, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// resetUnverifiedAccount removes every credential of an account nobody proved
+// to own, so that its creator can't get into it once the owner links it.
+// Verifies the email at the same time.
+func resetUnverifiedAccount(tx *gorm.DB, user *models.User) error {
+	now := time.Now()
+	err := tx.Model(user).Updates(map[string]interface{}{
+		"password":          "",
+		"totp_secret":       "",
+		"totp_enabled_at":   nil,
+		"totp_last_step":    0,
+		"email_verified_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	user.Password = ""
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.EmailVerifiedAt = &now
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.PasswordReset{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Update("revoked_at", now).Error
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/mailer"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/passwords"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+	"github.com/wisnuuakbr/blog-rest-go/internal/validations"
+	"gorm.io/gorm"
+)
+
+// Forgot Password
+// Always answers the same way, so it can't be used to find out which emails are registered
+func ForgotPassword(c *gin.Context) {
+	var userInput struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&userInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	message := "If the email is registered, a password reset link has been sent"
+
+	var user models.User
+	if err := initializers.DB.Where("email = ?", userInput.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": message,
+		})
+		return
+	}
+
+	raw, hash, err := tokens.NewOpaqueToken()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	ttl := config.GetDuration("PASSWORD_RESET_TTL", time.Hour)
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the latest link works
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.PasswordReset{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PasswordReset{
+			UserID:    user.ID,
+			TokenHash: hash,
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.GetEnv("APP_URL", "http://localhost:3000"), raw)
+	err = mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf("Hi %s,\n\nUse the link below to reset your password. It expires in %s.\n\n%s", user.Name, ttl, link),
+	})
+	// Failing here would tell that the email is registered
+	if err != nil {
+		log.Println("Error sending password reset email:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
+
+// Reset Password
+// The token can be used once, and every existing session is logged out afterwards
+func ResetPassword(c *gin.Context) {
+	var userInput struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&userInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Check the token first, so the password policy only runs for valid requests
+	var reset models.PasswordReset
+	err := initializers.DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokens.HashToken(userInput.Token), time.Now()).
+		First(&reset).Error
+	if err != nil {
+		format_errors.RecordNotFound(c, err, "The reset token is invalid or has expired")
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, reset.UserID).Error; err != nil {
+		format_errors.RecordNotFound(c, err, "The reset token is invalid or has expired")
+		return
+	}
+
+	if errs := passwords.DefaultPolicy().Validate("Password", userInput.Password, user.Name, user.Email); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": errs,
+		})
+		return
+	}
+
+	hashPassword, err := passwords.Hash(userInput.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to hash password",
+		})
+		return
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Only one concurrent request can use the token
+		result := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Model(&user).Update("password", hashPassword).Error
+	})
+
+	if err != nil {
+		format_errors.RecordNotFound(c, err, "The reset token is invalid or has expired")
+		return
+	}
+
+	if err := tokens.RevokeAllForUser(user.ID, time.Now()); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Your password has been reset, please login again",
+	})
+}
@@ -1,16 +1,25 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/gosimple/slug"
+	"github.com/wisnuuakbr/blog-rest-go/config"
 	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
 	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
 	"github.com/wisnuuakbr/blog-rest-go/internal/helpers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/markdown"
 	"github.com/wisnuuakbr/blog-rest-go/internal/models"
 	"github.com/wisnuuakbr/blog-rest-go/internal/pagination"
+	"github.com/wisnuuakbr/blog-rest-go/internal/policies"
+	"github.com/wisnuuakbr/blog-rest-go/internal/revisions"
 	"github.com/wisnuuakbr/blog-rest-go/internal/validations"
 	"gorm.io/gorm"
 )
@@ -22,6 +31,12 @@ func CreatePost(c *gin.Context) {
 		Title      string `json:"title" binding:"required,min=2,max=200"`
 		Body       string `json:"body" binding:"required"`
 		CategoryId uint   `json:"category_id" binding:"required,min=1"`
+		// New posts are drafts unless they are published right away
+		Status string `json:"status" binding:"omitempty,oneof=draft published"`
+		// A draft can be published later by the scheduler
+		PublishAt *time.Time `json:"publish_at"`
+		// Unknown tags are created
+		Tags []string `json:"tags" binding:"max=10,dive,min=1,max=50"`
 	}
 
 	if err := c.ShouldBindJSON(&userInput); err != nil {
@@ -34,6 +49,16 @@ func CreatePost(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
+		return
+	}
+
+	if userInput.PublishAt != nil && (userInput.Status == models.PostPublished || !userInput.PublishAt.After(time.Now())) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"PublishAt": "The publish time must be in the future and the post a draft",
+			},
+		})
+		return
 	}
 
 	if !validations.IsExistValue("categories", "id", userInput.CategoryId) {
@@ -47,18 +72,40 @@ func CreatePost(c *gin.Context) {
 	}
 
 	// Create a post
-	authID := helpers.GetAuthUser(c).ID
+	authUser := helpers.GetAuthUser(c)
+	if config.GetBool("REQUIRE_VERIFIED_EMAIL_TO_POST", false) && !authUser.EmailVerified {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "Please verify your email before creating posts",
+		})
+		return
+	}
+	authID := authUser.ID
 
 	post := models.Post{
 		Title:      userInput.Title,
 		Body:       userInput.Body,
 		CategoryID: userInput.CategoryId,
 		UserID:     authID,
+		Status:     models.PostDraft,
+	}
+	if userInput.Status == models.PostPublished {
+		post.Transition(models.PostPublished, time.Now())
 	}
+	post.PublishAt = userInput.PublishAt
 
-	result := initializers.DB.Create(&post)
+	// The first revision is the post as created
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		if err := setPostTags(tx, &post, userInput.Tags); err != nil {
+			return err
+		}
+		_, err := revisions.Record(tx, &post, authID)
+		return err
+	})
 
-	if result.Error != nil {
+	if err != nil {
 		format_errors.InternalServerError(c)
 		return
 	}
@@ -90,10 +137,48 @@ func GetPost(c *gin.Context) {
 		return
 	}
 
+	// Drafts of other users are hidden, ?status= filters by status
+	status := c.Query("status")
+	if status != "" && status != models.PostDraft && status != models.PostPublished && status != models.PostArchived {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status parameter"})
+		return
+	}
+
+	// ?tags=go,web filters by tag slugs, posts need any of them or all of
+	// them with ?match=all
+	match := c.DefaultQuery("match", "any")
+	if match != "any" && match != "all" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match parameter"})
+		return
+	}
+	var tagSlugs []string
+	seenTags := map[string]bool{}
+	for _, tagSlug := range strings.Split(c.Query("tags"), ",") {
+		if tagSlug = slug.Make(tagSlug); tagSlug != "" && !seenTags[tagSlug] {
+			seenTags[tagSlug] = true
+			tagSlugs = append(tagSlugs, tagSlug)
+		}
+	}
+
 	preloadFunc := func(query *gorm.DB) *gorm.DB {
+		query = query.Scopes(policies.VisiblePosts(helpers.GetAuthUser(c)))
+		if status != "" {
+			query = query.Where("posts.status = ?", status)
+		}
+		if len(tagSlugs) > 0 {
+			tagged := initializers.DB.Table("post_tags").
+				Select("post_tags.post_id").
+				Joins("JOIN tags ON tags.id = post_tags.tag_id").
+				Where("tags.slug IN ?", tagSlugs).
+				Group("post_tags.post_id")
+			if match == "all" {
+				tagged = tagged.Having("COUNT(DISTINCT tags.id) = ?", len(tagSlugs))
+			}
+			query = query.Where("posts.id IN (?)", tagged)
+		}
 		return query.Preload("User", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, email")
-		})
+		}).Preload("Tags")
 	}
 
 	result, err := pagination.Paginate(initializers.DB, page, perPage, preloadFunc, &posts)
@@ -117,9 +202,10 @@ func ShowPost(c *gin.Context) {
 	// find post
 	var post models.Post
 
-	result := initializers.DB.Preload("User", func(db *gorm.DB) *gorm.DB {
+	// Drafts of other users are not found
+	result := initializers.DB.Scopes(policies.VisiblePosts(helpers.GetAuthUser(c))).Preload("User", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, name")
-	}).First(&post, id)
+	}).Preload("Tags").First(&post, id)
 
 	if err := result.Error; err != nil {
 		format_errors.RecordNotFound(c, err)
@@ -127,10 +213,86 @@ func ShowPost(c *gin.Context) {
 	}
 
 	// Return the post
+	respondWithPost(c, &post)
+}
+
+// Show Post by Slug
+// Old slugs of the post redirect to the current one
+func ShowPostBySlug(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+
+	var post models.Post
+	err := initializers.DB.Scopes(policies.VisiblePosts(authUser)).Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name")
+	}).Preload("Tags").Where("slug = ?", c.Param("slug")).First(&post).Error
+
+	if err == nil {
+		respondWithPost(c, &post)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	var oldSlug models.PostSlug
+	if err := initializers.DB.Where("slug = ?", c.Param("slug")).First(&oldSlug).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+	if err := initializers.DB.Scopes(policies.VisiblePosts(authUser)).First(&post, oldSlug.PostID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	location := "/api/posts/by-slug/" + url.PathEscape(post.Slug)
+	if c.Request.URL.RawQuery != "" {
+		location += "?" + c.Request.URL.RawQuery
+	}
+	c.Redirect(http.StatusMovedPermanently, location)
+}
+
+// setPostTags replaces the tags of the post, creating the unknown ones
+func setPostTags(tx *gorm.DB, post *models.Post, names []string) error {
+	tags, err := models.FindOrCreateTags(tx, names)
+	if err != nil {
+		return err
+	}
+	return tx.Model(post).Association("Tags").Replace(tags)
+}
+
+// respondWithPost writes the post with the body in the ?format= asked for:
+// markdown (the source, by default), html or plain text
+func respondWithPost(c *gin.Context, post *models.Post) {
+	format := c.DefaultQuery("format", "markdown")
+	if format != "markdown" && format != "html" && format != "plain" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format parameter"})
+		return
+	}
+
+	if format != "markdown" {
+		// Posts saved before markdown rendering, or with an older renderer
+		if post.BodyHash != markdown.Hash(post.Body) {
+			if err := post.Render(); err != nil {
+				format_errors.InternalServerError(c)
+				return
+			}
+			initializers.DB.Model(post).UpdateColumns(map[string]interface{}{
+				"body_html": post.BodyHTML,
+				"body_hash": post.BodyHash,
+			})
+		}
+
+		post.Body = post.BodyHTML
+		if format == "plain" {
+			post.Body = markdown.Plain(post.BodyHTML)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"post": post,
+		"post":   post,
+		"format": format,
 	})
-
 }
 
 // Update Post
@@ -142,6 +304,8 @@ func UpdatePost(c *gin.Context) {
 	var userInput struct {
 		Title      string `json:"title" binding:"required,min=2,max=200"`
 		Body       string `json:"body" binding:"required"`
+		// Replaces the tags of the post, they stay the same when it is missing
+		Tags *[]string `json:"tags" binding:"omitempty,max=10,dive,min=1,max=50"`
 	}
 
 	if err := c.ShouldBindJSON(&userInput); err != nil {
@@ -167,25 +331,63 @@ func UpdatePost(c *gin.Context) {
 		return
 	}
 
-	// Prepare data to update
-	authID := helpers.GetAuthUser(c).ID
+	// Only the author or an editor can update the post
+	if !policies.CanUpdatePost(helpers.GetAuthUser(c), &post) {
+		format_errors.Forbidden(c)
+		return
+	}
+
+	// Prepare data to update, the author stays the same
 	updatePost := models.Post{
 		Title:      userInput.Title,
 		Body:       userInput.Body,
-		UserID:     authID,
+		BodyHTML:   post.BodyHTML,
+		BodyHash:   post.BodyHash,
 	}
 
-	// Update the post
-	result = initializers.DB.Model(&post).Updates(&updatePost)
+	// The HTML is only rendered again when the body changed
+	if err := updatePost.Render(); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
 
-	if result.Error != nil {
+	// Update the post and keep the new content as a revision
+	changed := post.Title != updatePost.Title || post.Body != updatePost.Body
+	retitled := post.Title != updatePost.Title
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := revisions.Baseline(tx, &post); err != nil {
+			return err
+		}
+		if err := tx.Model(&post).Select("title", "body", "body_html", "body_hash").Updates(&updatePost).Error; err != nil {
+			return err
+		}
+		post.Title, post.Body = updatePost.Title, updatePost.Body
+		post.BodyHTML, post.BodyHash = updatePost.BodyHTML, updatePost.BodyHash
+		if retitled {
+			if err := post.Reslug(tx); err != nil {
+				return err
+			}
+		}
+		if userInput.Tags != nil {
+			if err := setPostTags(tx, &post, *userInput.Tags); err != nil {
+				return err
+			}
+		}
+		if !changed {
+			return nil
+		}
+		_, err := revisions.Record(tx, &post, helpers.GetAuthUser(c).ID)
+		return err
+	})
+
+	if err != nil {
 		format_errors.InternalServerError(c)
 		return
 	}
 
 	// Return the post
 	c.JSON(http.StatusOK, gin.H{
-		"post": updatePost,
+		"post": post,
 	})
 }
 
@@ -201,10 +403,144 @@ func DeletePost(c *gin.Context) {
 		return
 	}
 
+	// Only the author or an editor can delete the post
+	if !policies.CanDeletePost(helpers.GetAuthUser(c), &post) {
+		format_errors.Forbidden(c)
+		return
+	}
+
 	// Delete the post
-	initializers.DB.Unscoped().Delete(&post)
+	if err := initializers.DB.Unscoped().Delete(&post).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "The post has been deleted successfully",
 	})
 }
+
+// Publish Post
+// Publishes a draft, or an archived post again
+func PublishPost(c *gin.Context) {
+	changePostStatus(c, models.PostPublished, "The post has been published")
+}
+
+// Unpublish Post
+// Turns a published post back into a draft
+func UnpublishPost(c *gin.Context) {
+	changePostStatus(c, models.PostDraft, "The post has been unpublished")
+}
+
+// Archive Post
+func ArchivePost(c *gin.Context) {
+	changePostStatus(c, models.PostArchived, "The post has been archived")
+}
+
+// Schedule Post
+// The draft is published by the scheduler at publish_at
+func SchedulePost(c *gin.Context) {
+	var userInput struct {
+		PublishAt *time.Time `json:"publish_at" binding:"required"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	if !userInput.PublishAt.After(time.Now()) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"PublishAt": "The publish time must be in the future",
+			},
+		})
+		return
+	}
+
+	schedulePost(c, userInput.PublishAt, "The post has been scheduled")
+}
+
+// Unschedule Post
+// The post stays a draft
+func UnschedulePost(c *gin.Context) {
+	schedulePost(c, nil, "The post is not scheduled anymore")
+}
+
+func schedulePost(c *gin.Context, publishAt *time.Time, message string) {
+	var post models.Post
+	if err := initializers.DB.First(&post, c.Param("id")).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// Only the author or an editor can schedule the post
+	if !policies.CanUpdatePost(helpers.GetAuthUser(c), &post) {
+		format_errors.Forbidden(c)
+		return
+	}
+
+	if post.Status != models.PostDraft {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Only drafts can be scheduled",
+		})
+		return
+	}
+
+	// The scheduler may have published the post in the meantime
+	result := initializers.DB.Model(&post).Where("status = ?", models.PostDraft).Update("publish_at", publishAt)
+	if result.Error != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Only drafts can be scheduled",
+		})
+		return
+	}
+	post.PublishAt = publishAt
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"post":    post,
+	})
+}
+
+func changePostStatus(c *gin.Context, status, message string) {
+	var post models.Post
+	if err := initializers.DB.First(&post, c.Param("id")).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// Only the author or an editor can change the status
+	if !policies.CanUpdatePost(helpers.GetAuthUser(c), &post) {
+		format_errors.Forbidden(c)
+		return
+	}
+
+	from := post.Status
+	if err := post.Transition(status, time.Now()); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The post can't go from " + from + " to " + status,
+		})
+		return
+	}
+
+	// The scheduler may have published the post in the meantime
+	result := initializers.DB.Model(&post).Where("status = ?", from).Select("status", "published_at", "publish_at").Updates(&post)
+	if result.Error != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The post has changed, please try again",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"post":    post,
+	})
+}
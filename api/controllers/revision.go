@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/helpers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/policies"
+	"github.com/wisnuuakbr/blog-rest-go/internal/revisions"
+	"gorm.io/gorm"
+)
+
+// Get Revisions
+// Lists the revisions of the post, the newest first
+func GetRevisions(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	var postRevisions []models.PostRevision
+	err := initializers.DB.Where("post_id = ?", post.ID).
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		Order("number desc").
+		Find(&postRevisions).Error
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revisions":      postRevisions,
+		"revision_limit": revisions.Limit(&post),
+	})
+}
+
+// Diff Revisions
+// Unified diff between the ?from= and ?to= revision numbers, to defaults to the latest
+func DiffRevisions(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from parameter"})
+		return
+	}
+
+	var fromRevision, toRevision models.PostRevision
+	if err := initializers.DB.Where("post_id = ? AND number = ?", post.ID, from).First(&fromRevision).Error; err != nil {
+		format_errors.RecordNotFound(c, err, "The revision not found")
+		return
+	}
+
+	query := initializers.DB.Where("post_id = ?", post.ID)
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := strconv.Atoi(toStr)
+		if err != nil || to < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to parameter"})
+			return
+		}
+		query = query.Where("number = ?", to)
+	}
+	if err := query.Order("number desc").First(&toRevision).Error; err != nil {
+		format_errors.RecordNotFound(c, err, "The revision not found")
+		return
+	}
+
+	diff, err := revisions.Diff(&fromRevision, &toRevision)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// ?format=text returns the patch itself
+	if c.Query("format") == "text" {
+		c.String(http.StatusOK, diff)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": fromRevision.Number,
+		"to":   toRevision.Number,
+		"diff": diff,
+	})
+}
+
+// Restore Revision
+// Copies the old revision into the post, as a new revision
+func RestoreRevision(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	var revision models.PostRevision
+	if err := initializers.DB.Where("post_id = ? AND number = ?", post.ID, c.Param("number")).First(&revision).Error; err != nil {
+		format_errors.RecordNotFound(c, err, "The revision not found")
+		return
+	}
+
+	var restored *models.PostRevision
+	retitled := post.Title != revision.Title
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		post.Title, post.Body = revision.Title, revision.Body
+		if err := post.Render(); err != nil {
+			return err
+		}
+		if err := tx.Model(&post).Select("title", "body", "body_html", "body_hash").Updates(&post).Error; err != nil {
+			return err
+		}
+		if retitled {
+			if err := post.Reslug(tx); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		restored, err = revisions.Record(tx, &post, helpers.GetAuthUser(c).ID)
+		return err
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "The revision has been restored",
+		"post":     post,
+		"revision": restored,
+	})
+}
+
+// Update Revision Limit
+// Sets how many revisions of the post are kept, null goes back to the default
+// and 0 keeps them all
+func UpdateRevisionLimit(c *gin.Context) {
+	var userInput struct {
+		Limit *int `json:"limit" binding:"omitempty,min=0"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&post).Update("revision_limit", userInput.Limit).Error; err != nil {
+			return err
+		}
+		post.RevisionLimit = userInput.Limit
+		return revisions.Prune(tx, &post)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revision_limit": revisions.Limit(&post),
+	})
+}
+
+// revisionPost finds the post of the route, its history is only open to
+// the users who can update it
+func revisionPost(c *gin.Context) (models.Post, bool) {
+	var post models.Post
+	if err := initializers.DB.First(&post, c.Param("id")).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return post, false
+	}
+
+	if !policies.CanUpdatePost(helpers.GetAuthUser(c), &post) {
+		format_errors.Forbidden(c)
+		return post, false
+	}
+	return post, true
+}
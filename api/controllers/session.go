@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/helpers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+)
+
+// Get Sessions
+// Lists where the current user is logged in
+func GetSessions(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var sessions []models.Session
+	err := initializers.DB.Where("user_id = ? AND revoked_at IS NULL", authUser.ID).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	var currentID uint
+	if current := helpers.GetAuthSession(c); current != nil {
+		currentID = current.ID
+	}
+
+	result := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, gin.H{
+			"id":           session.ID,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"created_at":   session.CreatedAt,
+			"last_seen_at": session.LastSeenAt,
+			"current":      session.ID == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": result,
+	})
+}
+
+// Revoke Session
+func RevokeSession(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var session models.Session
+	err := initializers.DB.Where("user_id = ? AND revoked_at IS NULL", authUser.ID).First(&session, c.Param("id")).Error
+	if err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if err := tokens.RevokeSession(&session); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// Revoking the current session is a logout
+	if current := helpers.GetAuthSession(c); current != nil && current.ID == session.ID {
+		clearAuthCookies(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The session has been revoked",
+	})
+}
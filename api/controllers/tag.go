@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm"
+)
+
+type tagWithCount struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	PostsCount int64  `json:"posts_count"`
+}
+
+// tagsWithCount counts the published posts of the tags
+func tagsWithCount() *gorm.DB {
+	return initializers.DB.Model(&models.Tag{}).
+		Select("tags.id, tags.name, tags.slug, COUNT(posts.id) AS posts_count").
+		Joins("LEFT JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("LEFT JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL AND posts.status = ?", models.PostPublished).
+		Group("tags.id")
+}
+
+// Get Tags
+// Lists the tags with the number of published posts, the most used first
+func GetTags(c *gin.Context) {
+	var tags []tagWithCount
+	if err := tagsWithCount().Order("posts_count desc, tags.name").Scan(&tags).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags": tags,
+	})
+}
+
+// Show Tag
+// The posts of the tag are listed by GET /api/posts?tags=<slug>
+func ShowTag(c *gin.Context) {
+	var tags []tagWithCount
+	if err := tagsWithCount().Where("tags.slug = ?", c.Param("slug")).Scan(&tags).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+	if len(tags) == 0 {
+		format_errors.RecordNotFound(c, gorm.ErrRecordNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tag": tags[0],
+	})
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/throttle"
+)
+
+// loginLocked answers 429 with Retry-After when the email or the client IP is locked
+func loginLocked(c *gin.Context, email string) bool {
+	store := throttle.DefaultStore()
+	now := time.Now()
+
+	var wait time.Duration
+	for _, key := range []string{throttle.EmailKey(email), throttle.IPKey(c.ClientIP())} {
+		retryAfter, err := throttle.RetryAfter(store, key, now)
+		if err != nil {
+			format_errors.InternalServerError(c)
+			return true
+		}
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+	}
+
+	if wait == 0 {
+		return false
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed login attempts, please try again later",
+		"retry_after": seconds,
+	})
+	return true
+}
+
+// recordLoginFailure counts a failure for the email and the client IP
+func recordLoginFailure(c *gin.Context, email string) {
+	store := throttle.DefaultStore()
+	now := time.Now()
+
+	if _, err := throttle.Fail(store, throttle.EmailKey(email), throttle.EmailPolicy(), now); err != nil {
+		log.Println("Failed to record the login attempt:", err)
+	}
+	if _, err := throttle.Fail(store, throttle.IPKey(c.ClientIP()), throttle.IPPolicy(), now); err != nil {
+		log.Println("Failed to record the login attempt:", err)
+	}
+}
+
+// resetLoginFailures forgets the failures of the email. The IP counter is kept,
+// otherwise one valid account would be enough to keep guessing other passwords.
+func resetLoginFailures(email string) {
+	if err := throttle.DefaultStore().Reset(throttle.EmailKey(email)); err != nil {
+		log.Println("Failed to reset the login attempts:", err)
+	}
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+)
+
+// Refresh Token
+// Exchanges the refresh token for a new access and refresh token. A token sent
+// in the body gets its replacements back in the body, a cookie gets cookies.
+func RefreshToken(c *gin.Context) {
+	var userInput struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	c.ShouldBindJSON(&userInput)
+
+	raw := userInput.RefreshToken
+	inBody := raw != ""
+	if !inBody {
+		raw, _ = c.Cookie(tokens.RefreshCookie)
+	}
+
+	if raw == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Refresh token is required",
+		})
+		return
+	}
+
+	refreshToken, current, err := tokens.RotateRefreshToken(raw)
+	if err != nil {
+		if errors.Is(err, tokens.ErrInvalidToken) || errors.Is(err, tokens.ErrTokenReused) {
+			clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid refresh token",
+			})
+			return
+		}
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, current.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid refresh token",
+		})
+		return
+	}
+
+	session, err := tokens.FindSessionByFamily(current.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid refresh token",
+		})
+		return
+	}
+	tokens.TouchSession(session, c.ClientIP())
+
+	accessToken, err := tokens.NewAccessToken(user.ID, session.ID)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	respondWithTokens(c, accessToken, refreshToken, inBody, "Token refreshed")
+}
+
+// completeLogin is called once the user proved who they are. With 2FA enabled
+// that is not enough, the client has to send the challenge with a code to /api/login/2fa
+func completeLogin(c *gin.Context, user *models.User, returnToken bool) {
+	if user.TOTPEnabledAt != nil {
+		challenge, err := tokens.NewTwoFactorChallenge(user.ID, returnToken)
+		if err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge":           challenge,
+		})
+		return
+	}
+
+	// Generate a short-lived access token and a refresh token to renew it
+	issueTokens(c, user, returnToken, "Welcome "+user.Name+"!")
+}
+
+// issueTokens starts a new session for the user
+func issueTokens(c *gin.Context, user *models.User, inBody bool, message string) {
+	session, refreshToken, err := tokens.StartSession(user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to create token",
+		})
+		return
+	}
+
+	accessToken, err := tokens.NewAccessToken(user.ID, session.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to create token",
+		})
+		return
+	}
+
+	respondWithTokens(c, accessToken, refreshToken, inBody, message)
+}
+
+func respondWithTokens(c *gin.Context, accessToken, refreshToken string, inBody bool, message string) {
+	if inBody {
+		c.JSON(http.StatusOK, gin.H{
+			"message":       message,
+			"token_type":    "Bearer",
+			"access_token":  accessToken,
+			"expires_in":    int(tokens.AccessTTL().Seconds()),
+			"refresh_token": refreshToken,
+		})
+		return
+	}
+
+	setAuthCookies(c, accessToken, refreshToken)
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
+
+func setAuthCookies(c *gin.Context, accessToken, refreshToken string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(tokens.AccessCookie, accessToken, int(tokens.AccessTTL().Seconds()), "", "", false, true)
+	c.SetCookie(tokens.RefreshCookie, refreshToken, int(tokens.RefreshTTL().Seconds()), "", "", false, true)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie(tokens.AccessCookie, "", -1, "", "", false, true)
+	c.SetCookie(tokens.RefreshCookie, "", -1, "", "", false, true)
+}
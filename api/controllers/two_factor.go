@@ -0,0 +1,327 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+	"github.com/wisnuuakbr/blog-rest-go/internal/totp"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+// Setup Two Factor
+// Generates a new secret, 2FA stays disabled until the first code is verified
+func SetupTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if err := initializers.DB.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(config.GetEnv("APP_NAME", "Blog Rest Go"), user.Email, secret),
+	})
+}
+
+// Enable Two Factor
+// Verifies the first code and returns the recovery codes, they are only shown once
+func EnableTwoFactor(c *gin.Context) {
+	var userInput struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Two-factor authentication has not been set up",
+		})
+		return
+	}
+
+	step, valid := totp.Validate(user.TOTPSecret, userInput.Code, time.Now())
+	if !valid {
+		invalidSecondFactor(c)
+		return
+	}
+
+	var codes []string
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled_at": time.Now(),
+			"totp_last_step":  step,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication has been enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// Disable Two Factor
+func DisableTwoFactor(c *gin.Context) {
+	var userInput struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	// Users without a password are confirmed by the code below
+	if user.Password != "" && !confirmIdentity(c, &user, userInput.Password, "") {
+		return
+	}
+
+	valid, err := verifySecondFactor(&user, userInput.Code, userInput.RecoveryCode)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+	if !valid {
+		invalidSecondFactor(c)
+		return
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication has been disabled",
+	})
+}
+
+// Regenerate Recovery Codes
+// The previous recovery codes stop working
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var userInput struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	valid, err := verifySecondFactor(&user, userInput.Code, "")
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+	if !valid {
+		invalidSecondFactor(c)
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(initializers.DB, user.ID)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+// Login Two Factor
+// Second step of the login for users with 2FA enabled
+func LoginTwoFactor(c *gin.Context) {
+	var userInput struct {
+		Challenge    string `json:"challenge" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if !bindInput(c, &userInput) {
+		return
+	}
+
+	claims, err := tokens.ParseTwoFactorChallenge(userInput.Challenge)
+	if err != nil {
+		if errors.Is(err, tokens.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "The login challenge is invalid or has expired",
+			})
+			return
+		}
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, uint(claims["sub"].(float64))).Error; err != nil || user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "The login challenge is invalid or has expired",
+		})
+		return
+	}
+
+	// Codes are guessed as easily as passwords
+	if loginLocked(c, user.Email) {
+		return
+	}
+
+	valid, err := verifySecondFactor(&user, userInput.Code, userInput.RecoveryCode)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+	if !valid {
+		recordLoginFailure(c, user.Email)
+		invalidSecondFactor(c)
+		return
+	}
+	resetLoginFailures(user.Email)
+
+	// The challenge can only be used once
+	expiresAt := time.Unix(int64(claims["exp"].(float64)), 0)
+	if err := tokens.Revoke(claims["jti"].(string), expiresAt); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	returnToken, _ := claims["return_token"].(bool)
+	issueTokens(c, &user, returnToken, "Welcome "+user.Name+"!")
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code
+func verifySecondFactor(user *models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, valid := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !valid {
+			return false, nil
+		}
+
+		// Refuse a code of a step that has already been used
+		result := initializers.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		return result.RowsAffected == 1, result.Error
+	}
+
+	if recoveryCode != "" {
+		hash := tokens.HashToken(totp.NormalizeRecoveryCode(recoveryCode))
+		result := initializers.DB.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hash).
+			Update("used_at", time.Now())
+		return result.RowsAffected == 1, result.Error
+	}
+
+	return false, nil
+}
+
+func replaceRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		recoveryCodes := make([]models.RecoveryCode, 0, len(codes))
+		for _, code := range codes {
+			recoveryCodes = append(recoveryCodes, models.RecoveryCode{
+				UserID:   userID,
+				CodeHash: tokens.HashToken(code),
+			})
+		}
+		return tx.Create(&recoveryCodes).Error
+	})
+
+	return codes, err
+}
+
+func invalidSecondFactor(c *gin.Context) {
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error": "Invalid authentication code",
+	})
+}
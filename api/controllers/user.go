@@ -1,20 +1,24 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"github.com/golang-jwt/jwt"
 	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/accounts"
 	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/helpers"
 	"github.com/wisnuuakbr/blog-rest-go/internal/models"
 	"github.com/wisnuuakbr/blog-rest-go/internal/pagination"
+	"github.com/wisnuuakbr/blog-rest-go/internal/passwords"
+	"github.com/wisnuuakbr/blog-rest-go/internal/throttle"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
 	"github.com/wisnuuakbr/blog-rest-go/internal/validations"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // Register User
@@ -22,7 +26,7 @@ func Register(c *gin.Context) {
 	var userInput struct {
 		Name     string `json:"name" binding:"required,min=2,max=50"`
 		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required,min=6"`
+		Password string `json:"password" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&userInput); err != nil {
@@ -48,8 +52,16 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// Password policy validation
+	if errs := passwords.DefaultPolicy().Validate("Password", userInput.Password, userInput.Name, userInput.Email); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": errs,
+		})
+		return
+	}
+
 	// Hashing the password
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(userInput.Password), 10)
+	hashPassword, err := passwords.Hash(userInput.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to hash password",
@@ -60,14 +72,23 @@ func Register(c *gin.Context) {
 	user := models.User{
 		Name: 		userInput.Name,
 		Email: 		userInput.Email,
-		Password: 	string(hashPassword),
+		Password: 	hashPassword,
 	}
 
+	// New users are authors
+	user.RoleID = defaultRoleID(initializers.DB)
+
 	result := initializers.DB.Create(&user)
 	if result.Error != nil {
 		format_errors.InternalServerError(c)
 		return
 	}
+
+	// The account is created anyway, the user can ask for a new link
+	if err := sendVerificationEmail(&user); err != nil {
+		log.Println("Failed to send the verification email:", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"user": user,
 	})
@@ -78,6 +99,8 @@ func Login(c *gin.Context) {
 	var userInput struct {
 		Email string `json:"email" binding:"required,email"`
 		Password string `json:"password" binding:"required"`
+		// Return the tokens in the body instead of cookies (mobile and CLI clients)
+		ReturnToken bool `json:"return_token"`
 	}
 	
 	if c.ShouldBindJSON(&userInput) != nil {
@@ -87,11 +110,17 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Brute-force protection, by email and by IP
+	if loginLocked(c, userInput.Email) {
+		return
+	}
+
 	// Find user by email
 	var user models.User
 	initializers.DB.First(&user, "email = ?", userInput.Email)
 
 	if user.ID == 0 {
+		recordLoginFailure(c, userInput.Email)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid email or password",
 		})
@@ -99,48 +128,96 @@ func Login(c *gin.Context) {
 	}
 
 	// compare password
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(userInput.Password))
-	if err != nil {
+	valid, err := passwords.Verify(userInput.Password, user.Password)
+	if err != nil || !valid {
+		recordLoginFailure(c, userInput.Email)
 		c.JSON(http.StatusBadRequest, gin.H {
 			"error": "Invalid email or password",
 		})
 		return
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
-
-	// Sign in and get the complete encoded token as a string using the .env secret_key
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET_KEY")))
+	// Upgrade the hash made with an old algorithm or old parameters, the
+	// plain password is only available now
+	if passwords.NeedsRehash(user.Password) {
+		if hashPassword, err := passwords.Hash(userInput.Password); err == nil {
+			initializers.DB.Model(&user).Update("password", hashPassword)
+		}
+	}
 
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Failed to create token",
-		})
-		return
+	// With 2FA the failures are reset once the code is verified too
+	if user.TOTPEnabledAt == nil {
+		resetLoginFailures(user.Email)
 	}
 
-	// Set expired
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("Authorization", tokenString, 3600*24*30, "", "", false, true)
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Welcome " + user.Name + "!",
-	})
+	completeLogin(c, &user, userInput.ReturnToken)
 }
 
 // Logout
+// Clients that keep the tokens themselves can send the refresh token in the body
 func Logout(c *gin.Context) {
-	// Clear the cookie
-	c.SetCookie("Authorization", "", 0, "", "", false, true)
+	var userInput struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	c.ShouldBindJSON(&userInput)
+
+	// Revoke the access token, so a copy of it can't be used anymore
+	if claims := helpers.GetAuthClaims(c); claims != nil {
+		expiresAt := time.Unix(int64(claims["exp"].(float64)), 0)
+		if err := tokens.Revoke(claims["jti"].(string), expiresAt); err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	}
+
+	// Revoke the session, which revokes its refresh tokens too
+	if session := helpers.GetAuthSession(c); session != nil {
+		if err := tokens.RevokeSession(session); err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	}
+
+	// Revoke the refresh token family of this login
+	refreshToken := userInput.RefreshToken
+	if refreshToken == "" {
+		refreshToken, _ = c.Cookie(tokens.RefreshCookie)
+	}
+	if refreshToken != "" {
+		if err := tokens.RevokeRefreshToken(refreshToken); err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	}
+
+	// Clear the cookies
+	clearAuthCookies(c)
 
 	c.JSON(http.StatusOK, gin.H{
 		"successMessage": "Logout successful",
 	})
 }
 
+// Logout Everywhere
+// Invalidates every token issued to the current user until now
+func LogoutAll(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	if err := tokens.RevokeAllForUser(authUser.ID, time.Now()); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	clearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"successMessage": "Logged out from all devices",
+	})
+}
+
 // Get all users
 func GetUsers(c *gin.Context) {
 	
@@ -250,7 +327,79 @@ func Update(c *gin.Context) {
 	})
 }
 
+// Update User Role
+func UpdateRole(c *gin.Context) {
+	id := c.Param("id")
+
+	var userInput struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&userInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	var role models.Role
+	if err := initializers.DB.Where("name = ?", userInput.Role).First(&role).Error; err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Role": "The role does not exist!",
+			},
+		})
+		return
+	}
+
+	if err := initializers.DB.Model(&user).Update("role_id", role.ID).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+	user.Role = &role
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// Unlock User
+// Clears the failed login attempts of the user's email
+func Unlock(c *gin.Context) {
+	id := c.Param("id")
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if err := throttle.DefaultStore().Reset(throttle.EmailKey(user.Email)); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The user has been unlocked",
+	})
+}
+
 // Temporary Delete User
+// The user's posts are moved to the trash together with the user, so they
+// stop showing up in the post listing until the user is restored.
 func Delete(c *gin.Context) {
 	id := c.Param("id")
 	var user models.User
@@ -261,13 +410,56 @@ func Delete(c *gin.Context) {
 		return
 	}
 
-	initializers.DB.Delete(&user)
+	// Use the same timestamp for the user and the posts, so restore knows
+	// which posts were trashed along with the user
+	deletedAt := time.Now()
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Post{}).Where("user_id = ?", user.ID).Update("deleted_at", deletedAt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Update("deleted_at", deletedAt).Error
+	})
+
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "The user has been deleted successfully",
 	})
 }
 
+// Restore User
+// Only the posts that were trashed together with the user are restored.
+func Restore(c *gin.Context) {
+	id := c.Param("id")
+	var user models.User
+
+	result := initializers.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&user, id)
+	if err := result.Error; err != nil {
+		format_errors.RecordNotFound(c, err, "The user is not in the trash")
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Post{}).Where("user_id = ? AND deleted_at = ?", user.ID, user.DeletedAt.Time).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&user).Update("deleted_at", nil).Error
+	})
+
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
 // Trashed User
 func GetTrashedUsers(c *gin.Context) {
 	var users []models.User
@@ -300,6 +492,8 @@ func GetTrashedUsers(c *gin.Context) {
 }
 
 // Permanent Delete
+// The user's posts, trashed or not, and everything else stored about the
+// user are deleted permanently as well.
 func PermanentDelete(c *gin.Context) {
 	id := c.Param("id")
 	var user models.User
@@ -309,7 +503,10 @@ func PermanentDelete(c *gin.Context) {
 		return
 	}
 
-	initializers.DB.Unscoped().Delete(&user)
+	if err := accounts.DeletePermanently(&user); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "The user has been deleted permanently",
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+	"github.com/wisnuuakbr/blog-rest-go/internal/helpers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/mailer"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+)
+
+// Verify Email
+func VerifyEmail(c *gin.Context) {
+	userID, email, err := tokens.ParseEmailVerificationToken(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "The verification link is invalid or has expired",
+		})
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// The link was sent to an email the user doesn't use anymore
+	if user.Email != email {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "The verification link is invalid or has expired",
+		})
+		return
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if err := initializers.DB.Model(&user).Update("email_verified_at", time.Now()).Error; err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Your email has been verified",
+	})
+}
+
+// Resend Verification Email
+func ResendVerification(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, authUser.ID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Your email is already verified",
+		})
+		return
+	}
+
+	if err := sendVerificationEmail(&user); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "A verification link has been sent to " + user.Email,
+	})
+}
+
+func sendVerificationEmail(user *models.User) error {
+	token, err := tokens.NewEmailVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/email/verify?token=%s", config.GetEnv("APP_URL", "http://localhost:3000"), url.QueryEscape(token))
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Hi %s,\n\nPlease verify your email by opening the link below.\n\n%s", user.Name, link),
+	})
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/internal/apikeys"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+)
+
+// apiKeyResources are the only routes API keys can be used for. GET requests
+// need the <resource>:read scope, every other method <resource>:write.
+// Account routes (logout, 2FA, API keys...) are not listed on purpose.
+var apiKeyResources = map[string]string{
+	"/api/posts":      "posts",
+	"/api/categories": "categories",
+	"/api/tags":       "tags",
+	"/api/comments":   "comments",
+	"/api/users":      "users",
+}
+
+func requireAPIKey(c *gin.Context, raw string) {
+	key, err := apikeys.Authenticate(raw)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	scope, ok := scopeForRequest(c)
+	if !ok || !apikeys.HasScope(key, scope) {
+		format_errors.Forbidden(c)
+		return
+	}
+
+	user := findUser(key.UserID)
+	if user.ID == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	authUser := newAuthUser(&user)
+	authUser.APIKeyID = key.ID
+
+	c.Set("authUser", authUser)
+	c.Next()
+}
+
+func scopeForRequest(c *gin.Context) (string, bool) {
+	resource, ok := resourceForPath(c.FullPath())
+	if !ok {
+		return "", false
+	}
+
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		return resource + ":read", true
+	}
+	return resource + ":write", true
+}
+
+func resourceForPath(path string) (string, bool) {
+	// Comments of a post (/api/posts/:id/comments/...) belong to comments
+	if strings.Contains(path+"/", "/comments/") {
+		return "comments", true
+	}
+
+	for prefix, resource := range apiKeyResources {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return resource, true
+		}
+	}
+	return "", false
+}
@@ -1,69 +1,140 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
-	"os"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
 	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/apikeys"
 	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
 )
 
 type AuthUser struct {
-	ID    uint   `json:"ID"`
-	Name  string `json:"Name"`
-	Email string `json:"Email"`
+	ID          uint     `json:"ID"`
+	Name        string   `json:"Name"`
+	Email       string   `json:"Email"`
+	Role        string   `json:"Role"`
+	Permissions []string `json:"Permissions"`
+	// EmailVerified is false until the user opens the verification link
+	EmailVerified bool `json:"EmailVerified"`
+	// APIKeyID is set when the request is authenticated with an API key
+	APIKeyID uint `json:"APIKeyID,omitempty"`
 }
 
+// Can reports whether the user's role grants the permission
+func (authUser *AuthUser) Can(permission string) bool {
+	if authUser == nil {
+		return false
+	}
+	for _, granted := range authUser.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAuth accepts the access token from the "Authorization: Bearer <token>"
+// header or from the Authorization cookie. When the header is present it always
+// wins, and a malformed header is rejected instead of falling back to the cookie.
+// A bearer credential starting with "blog_" is handled as a personal API key.
 func RequireAuth(c *gin.Context) {
-	tokenString, err := c.Cookie("Authorization")
+	tokenString, ok := accessTokenFromRequest(c)
+
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if apikeys.IsAPIKey(tokenString) {
+		requireAPIKey(c, tokenString)
+		return
+	}
 
+	// decode then validate the short-lived access token
+	claims, err := tokens.ParseAccessToken(tokenString)
 	if err != nil {
-		c.AbortWithStatus(http.StatusUnauthorized)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
 	}
 
-	// decode then validate the token and parse
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected singin method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("SECRET_KEY")), nil
-	})
+	// reject tokens revoked by logout
+	revoked, err := tokens.IsRevoked(claims["jti"].(string))
+	if err != nil || revoked {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// find user with token sub
+	user := findUser(claims["sub"])
 
-	if err != nil || !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		c.Abort()
+	if user.ID == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized",
+		})
 		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		// check expiration time
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.AbortWithStatus(http.StatusUnauthorized)
-		}
-		// find user with token sub
-		var user models.User
-		initializers.DB.Find(&user, claims["sub"])
-
-		if user.ID == 0 {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Unauthorized",
-			})
-			return
-		}
+	// reject tokens of a session revoked from the session list
+	session, err := tokens.FindActiveSession(user.ID, uint(claims["sid"].(float64)))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized",
+		})
+		return
+	}
 
-		authUser := AuthUser {
-			ID:		user.ID,
-			Name:	user.Name,
-			Email: 	user.Email,
+	// reject tokens issued before the user logged out everywhere, unless
+	// they belong to a session started since then
+	if tokens.IssuedBefore(claims, user.TokensRevokedAt) && !session.CreatedAt.After(*user.TokensRevokedAt) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized",
+		})
+		return
+	}
+	tokens.TouchSession(session, c.ClientIP())
+
+	// attach user to request
+	c.Set("authUser", newAuthUser(&user))
+	c.Set("authClaims", claims)
+	c.Set("authSession", session)
+	c.Next()
+}
+
+func findUser(id interface{}) models.User {
+	var user models.User
+	initializers.DB.Preload("Role.Permissions").Find(&user, id)
+	return user
+}
+
+func newAuthUser(user *models.User) AuthUser {
+	authUser := AuthUser{
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+	}
+	if user.Role != nil {
+		authUser.Role = user.Role.Name
+		authUser.Permissions = user.Role.PermissionNames()
+	}
+	return authUser
+}
+
+func accessTokenFromRequest(c *gin.Context) (string, bool) {
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return "", false
 		}
-		// attach user to request
-		c.Set("authUser", authUser)
-		c.Next()
-	}else{
-		c.AbortWithStatus(http.StatusUnauthorized)
+		return strings.TrimSpace(token), true
+	}
+
+	token, err := c.Cookie(tokens.AccessCookie)
+	if err != nil || token == "" {
+		return "", false
 	}
-}
\ No newline at end of file
+	return token, true
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisnuuakbr/blog-rest-go/internal/format_errors"
+)
+
+// RequirePermission must be used after RequireAuth, e.g.
+// r.DELETE("/...", middleware.RequirePermission("posts:delete:any"), handler)
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("authUser")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		authUser, ok := value.(AuthUser)
+		if !ok || !authUser.Can(permission) {
+			format_errors.Forbidden(c)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -4,23 +4,72 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/wisnuuakbr/blog-rest-go/api/controllers"
 	"github.com/wisnuuakbr/blog-rest-go/api/middleware"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
 )
 
 func GetRouter(r *gin.Engine) {
 	// User routes
 	r.POST("/api/register", controllers.Register)
 	r.POST("/api/login", controllers.Login)
+	r.POST("/api/login/2fa", controllers.LoginTwoFactor)
+	r.POST("/api/token/refresh", controllers.RefreshToken)
+	r.POST("/api/password/forgot", controllers.ForgotPassword)
+	r.POST("/api/password/reset", controllers.ResetPassword)
+	r.GET("/api/email/verify", controllers.VerifyEmail)
+	r.GET("/api/email/confirm-change", controllers.ConfirmEmailChange)
+	r.GET("/api/auth/oidc/login", controllers.OIDCLogin)
+	r.GET("/api/auth/oidc/callback", controllers.OIDCCallback)
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
 	r.Use(middleware.RequireAuth)
 	r.POST("/api/logout", controllers.Logout)
+	r.POST("/api/logout-all", controllers.LogoutAll)
+	r.POST("/api/email/resend", controllers.ResendVerification)
+	r.GET("/api/sessions", controllers.GetSessions)
+	r.DELETE("/api/sessions/:id", controllers.RevokeSession)
 
-	userRouter := r.Group("/api/users")
+	// Current user routes
+	meRouter := r.Group("/api/me")
+	{
+		meRouter.GET("/", controllers.GetMe)
+		meRouter.PUT("/password", controllers.ChangePassword)
+		meRouter.PUT("/email", controllers.ChangeEmail)
+		meRouter.POST("/export", controllers.RequestExport)
+		meRouter.GET("/exports", controllers.GetExports)
+		meRouter.GET("/exports/:id/download", controllers.DownloadExport)
+		meRouter.POST("/delete", controllers.RequestDeletion)
+		meRouter.DELETE("/delete", controllers.CancelDeletion)
+	}
+
+	// Two-factor authentication routes
+	twoFactorRouter := r.Group("/api/2fa")
+	{
+		twoFactorRouter.POST("/setup", controllers.SetupTwoFactor)
+		twoFactorRouter.POST("/enable", controllers.EnableTwoFactor)
+		twoFactorRouter.POST("/disable", controllers.DisableTwoFactor)
+		twoFactorRouter.POST("/recovery-codes", controllers.RegenerateRecoveryCodes)
+	}
+
+	// Personal API key routes
+	apiKeyRouter := r.Group("/api/api-keys")
+	{
+		apiKeyRouter.GET("/", controllers.GetAPIKeys)
+		apiKeyRouter.POST("/create", controllers.CreateAPIKey)
+		apiKeyRouter.PUT("/:id/update", controllers.UpdateAPIKey)
+		apiKeyRouter.DELETE("/:id/revoke", controllers.RevokeAPIKey)
+	}
+
+	// User management is admin-only
+	userRouter := r.Group("/api/users", middleware.RequirePermission(models.PermUsersManage))
 	{
 		userRouter.GET("/", controllers.GetUsers)
 		userRouter.GET("/:id/edit", controllers.Edit)
 		userRouter.PUT("/:id/update", controllers.Update)
 		userRouter.DELETE("/:id/delete", controllers.Delete)
+		userRouter.POST("/:id/restore", controllers.Restore)
 		userRouter.GET("/all-trash", controllers.GetTrashedUsers)
-		userRouter.DELETE("/delete-permanent/:id", controllers.PermanentDelete)
+		userRouter.PUT("/:id/role", controllers.UpdateRole)
+		userRouter.POST("/:id/unlock", controllers.Unlock)
+		userRouter.DELETE("/delete-permanent/:id", middleware.RequirePermission(models.PermUsersDeletePermanent), controllers.PermanentDelete)
 	}
 
 	// Post routes
@@ -29,8 +78,36 @@ func GetRouter(r *gin.Engine) {
 		postRouter.GET("/", controllers.GetPost)
 		postRouter.POST("/create", controllers.CreatePost)
 		postRouter.GET("/:id/show", controllers.ShowPost)
+		postRouter.GET("/by-slug/:slug", controllers.ShowPostBySlug)
 		postRouter.PUT("/:id/update", controllers.UpdatePost)
 		postRouter.DELETE("/:id/delete", controllers.DeletePost)
+		postRouter.POST("/:id/publish", controllers.PublishPost)
+		postRouter.POST("/:id/unpublish", controllers.UnpublishPost)
+		postRouter.POST("/:id/archive", controllers.ArchivePost)
+		postRouter.POST("/:id/schedule", controllers.SchedulePost)
+		postRouter.DELETE("/:id/schedule", controllers.UnschedulePost)
+		postRouter.GET("/:id/revisions", controllers.GetRevisions)
+		postRouter.GET("/:id/revisions/diff", controllers.DiffRevisions)
+		postRouter.POST("/:id/revisions/:number/restore", controllers.RestoreRevision)
+		postRouter.PUT("/:id/revisions/limit", controllers.UpdateRevisionLimit)
+		postRouter.GET("/:id/comments", controllers.GetComments)
+		postRouter.POST("/:id/comments/create", controllers.CreateComment)
+		postRouter.POST("/:id/comments/lock", controllers.LockComments)
+		postRouter.DELETE("/:id/comments/lock", controllers.UnlockComments)
+	}
+
+	// Comment routes
+	commentRouter := r.Group("/api/comments")
+	{
+		commentRouter.PUT("/:id/update", controllers.UpdateComment)
+		commentRouter.DELETE("/:id/delete", controllers.DeleteComment)
+	}
+
+	// Tag routes
+	tagRouter := r.Group("/api/tags")
+	{
+		tagRouter.GET("/", controllers.GetTags)
+		tagRouter.GET("/:slug", controllers.ShowTag)
 	}
 
 	// Category routes
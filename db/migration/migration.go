@@ -6,6 +6,7 @@ import (
 
 	"github.com/wisnuuakbr/blog-rest-go/config"
 	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/db/seeders"
 	"github.com/wisnuuakbr/blog-rest-go/internal/models"
 )
 
@@ -14,17 +15,53 @@ func init() {
 	initializers.ConnectDB()
 }
 
+// tables are dropped and migrated in this order
+var tables = []interface{}{
+	models.User{},
+	models.Post{},
+	models.Category{},
+	models.RefreshToken{},
+	models.RevokedToken{},
+	models.Role{},
+	models.Permission{},
+	models.PasswordReset{},
+	models.RecoveryCode{},
+	models.ExternalIdentity{},
+	models.APIKey{},
+	models.LoginAttempt{},
+	models.Session{},
+	models.DataExport{},
+	models.PostRevision{},
+	models.PostSlug{},
+	models.Tag{},
+	models.Comment{},
+}
+
+// joinTables are created by the many2many associations of the tables
+var joinTables = []interface{}{
+	"role_permissions",
+	"post_tags",
+}
+
 func main() {
-	err := initializers.DB.Migrator().DropTable(models.User{}, models.Post{}, models.Category{})
+	err := initializers.DB.Migrator().DropTable(append(joinTables, tables...)...)
 	if err != nil {
 		log.Fatal("Table dropping failed", err)
 	}
 
-	err = initializers.DB.AutoMigrate(models.User{}, models.Post{}, models.Category{})
+	err = initializers.DB.AutoMigrate(tables...)
 	if err != nil {
 		log.Fatal("Migration failed", err)
 	}
 
+	if err = seeders.SeedRoles(initializers.DB); err != nil {
+		log.Fatal("Seeding roles failed", err)
+	}
+
+	if err = seeders.SeedAdmin(initializers.DB); err != nil {
+		log.Fatal("Seeding admin failed", err)
+	}
+
 	// Don't forget to close the database connection when done
 	sqlDB, err := initializers.DB.DB()
 	if err != nil {
@@ -0,0 +1,38 @@
+package seeders
+
+import (
+	"os"
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/passwords"
+	"gorm.io/gorm"
+)
+
+// SeedAdmin creates the first admin from ADMIN_EMAIL and ADMIN_PASSWORD, if set
+func SeedAdmin(db *gorm.DB) error {
+	email, password := os.Getenv("ADMIN_EMAIL"), os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return nil
+	}
+
+	var role models.Role
+	if err := db.Where("name = ?", models.RoleAdmin).First(&role).Error; err != nil {
+		return err
+	}
+
+	hashPassword, err := passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	verifiedAt := time.Now()
+	admin := models.User{
+		Name:            "Admin",
+		Email:           email,
+		Password:        hashPassword,
+		EmailVerifiedAt: &verifiedAt,
+		RoleID:          &role.ID,
+	}
+	return db.Where(models.User{Email: email}).FirstOrCreate(&admin).Error
+}
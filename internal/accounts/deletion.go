@@ -0,0 +1,102 @@
+// Package accounts lets users export their data and delete their account
+package accounts
+
+import (
+	"os"
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// CoolingOff is how long a deletion request can be cancelled
+func CoolingOff() time.Duration {
+	return config.GetDuration("ACCOUNT_DELETION_COOLING_OFF", 14*24*time.Hour)
+}
+
+// ScheduleDeletion deletes the account once the cooling-off period is over
+func ScheduleDeletion(user *models.User, now time.Time) error {
+	scheduledAt := now.Add(CoolingOff())
+	if err := initializers.DB.Model(user).Update("deletion_scheduled_at", scheduledAt).Error; err != nil {
+		return err
+	}
+	user.DeletionScheduledAt = &scheduledAt
+	return nil
+}
+
+// CancelDeletion keeps the account
+func CancelDeletion(user *models.User) error {
+	if err := initializers.DB.Model(user).Update("deletion_scheduled_at", nil).Error; err != nil {
+		return err
+	}
+	user.DeletionScheduledAt = nil
+	return nil
+}
+
+// DeletePermanently deletes the user with the posts, trashed or not, and
+// everything else stored about the user. The comments of the posts go with
+// them.
+func DeletePermanently(user *models.User) error {
+	var exports []models.DataExport
+	if err := initializers.DB.Where("user_id = ?", user.ID).Find(&exports).Error; err != nil {
+		return err
+	}
+
+	owned := []interface{}{
+		&models.Post{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.APIKey{},
+		&models.RecoveryCode{},
+		&models.PasswordReset{},
+		&models.ExternalIdentity{},
+		&models.DataExport{},
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Comments on other posts lose their body, their replies stay in the
+		// thread. The author is unset when the user row goes.
+		err := tx.Model(&models.Comment{}).Where("user_id = ?", user.ID).Updates(map[string]interface{}{
+			"body":       "",
+			"deleted_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, model := range owned {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(user).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if export.FilePath != "" {
+			os.Remove(export.FilePath)
+		}
+	}
+	return nil
+}
+
+// PurgeDeletedAccounts deletes the accounts whose cooling-off period is over
+func PurgeDeletedAccounts(now time.Time) (int, error) {
+	var users []models.User
+	err := initializers.DB.Unscoped().Where("deletion_scheduled_at <= ?", now).Find(&users).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range users {
+		if err := DeletePermanently(&users[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
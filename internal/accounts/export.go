@@ -0,0 +1,262 @@
+package accounts
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/mailer"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	FormatJSON = "json"
+	FormatZIP  = "zip"
+)
+
+// exportTTL is how long the archive can be downloaded
+func exportTTL() time.Duration {
+	return config.GetDuration("EXPORT_TTL", 7*24*time.Hour)
+}
+
+// exportTimeout is how long an archive can take to build, past it the export
+// was lost by a crash or a restart
+func exportTimeout() time.Duration {
+	return config.GetDuration("EXPORT_TIMEOUT", 30*time.Minute)
+}
+
+func exportDir() string {
+	return config.GetEnv("EXPORT_DIR", "storage/exports")
+}
+
+// archive is everything stored about a user, the files of a ZIP export are
+// its sections
+type archive struct {
+	ExportedAt         time.Time                 `json:"exported_at"`
+	Profile            models.User               `json:"profile"`
+	Posts              []map[string]interface{}  `json:"posts"`
+	Comments           []models.Comment          `json:"comments"`
+	Sessions           []models.Session          `json:"sessions"`
+	APIKeys            []models.APIKey           `json:"api_keys"`
+	ExternalIdentities []models.ExternalIdentity `json:"external_identities"`
+}
+
+// BuildPendingExports builds the requested exports one at a time and emails
+// their users. The exports are claimed with SKIP LOCKED, so each one is built
+// by one instance only.
+func BuildPendingExports(ctx context.Context, now time.Time) error {
+	if err := failStaleExports(ctx, now); err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		export, err := claimPendingExport(ctx)
+		if err != nil {
+			return err
+		}
+		if export == nil {
+			return nil
+		}
+
+		if err := BuildExport(export); err != nil {
+			log.Println("Error building data export:", err)
+			continue
+		}
+		notifyExportReady(export)
+	}
+	return ctx.Err()
+}
+
+// claimPendingExport marks the oldest pending export as processing, it
+// returns nil when there is none
+func claimPendingExport(ctx context.Context) (*models.DataExport, error) {
+	var exports []models.DataExport
+
+	err := initializers.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", models.ExportPending).
+			Order("id").
+			Limit(1).
+			Find(&exports).Error
+		if err != nil || len(exports) == 0 {
+			return err
+		}
+
+		return tx.Model(&exports[0]).Updates(map[string]interface{}{
+			"status":     models.ExportProcessing,
+			"started_at": time.Now(),
+		}).Error
+	})
+	if err != nil || len(exports) == 0 {
+		return nil, err
+	}
+	return &exports[0], nil
+}
+
+// failStaleExports marks failed the exports still processing after the
+// timeout, so their users can request a new one
+func failStaleExports(ctx context.Context, now time.Time) error {
+	result := initializers.DB.WithContext(ctx).Model(&models.DataExport{}).
+		Where("status = ? AND started_at <= ?", models.ExportProcessing, now.Add(-exportTimeout())).
+		Update("status", models.ExportFailed)
+	if result.RowsAffected > 0 {
+		log.Printf("Marked %d stale data exports as failed", result.RowsAffected)
+	}
+	return result.Error
+}
+
+func notifyExportReady(export *models.DataExport) {
+	var user models.User
+	if err := initializers.DB.First(&user, export.UserID).Error; err != nil {
+		return
+	}
+
+	link := fmt.Sprintf("%s/api/me/exports/%d/download", config.GetEnv("APP_URL", "http://localhost:3000"), export.ID)
+	mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your data export is ready",
+		Body:    fmt.Sprintf("Hi %s,\n\nYour data export is ready, download it while logged in from the link below.\n\n%s", user.Name, link),
+	})
+}
+
+// BuildExport writes the archive of the export and marks it ready, or failed
+// when something goes wrong
+func BuildExport(export *models.DataExport) error {
+	path, err := writeExport(export)
+	if err != nil {
+		initializers.DB.Model(export).Update("status", models.ExportFailed)
+		return err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(exportTTL())
+	return initializers.DB.Model(export).Updates(map[string]interface{}{
+		"status":       models.ExportReady,
+		"file_path":    path,
+		"completed_at": now,
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+// DeleteExpiredExports removes the archives that can't be downloaded anymore
+func DeleteExpiredExports(now time.Time) error {
+	var exports []models.DataExport
+	if err := initializers.DB.Where("expires_at <= ?", now).Find(&exports).Error; err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if export.FilePath != "" {
+			if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := initializers.DB.Delete(&export).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeExport(export *models.DataExport) (string, error) {
+	data, err := collect(export.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(exportDir(), 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(exportDir(), fmt.Sprintf("%d-%d.%s", export.UserID, export.ID, export.Format))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if export.Format == FormatZIP {
+		err = writeZIP(file, data)
+	} else {
+		err = writeJSON(file, data)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+func collect(userID uint) (*archive, error) {
+	data := archive{ExportedAt: time.Now()}
+
+	db := initializers.DB
+	if err := db.Unscoped().Preload("Role").First(&data.Profile, userID).Error; err != nil {
+		return nil, err
+	}
+	// Every column of the posts, trashed ones included
+	if err := db.Unscoped().Model(&models.Post{}).Where("user_id = ?", userID).Order("id").Find(&data.Posts).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&data.Comments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&data.Sessions).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&data.APIKeys).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&data.ExternalIdentities).Error; err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func writeJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func writeZIP(w io.Writer, data *archive) error {
+	writer := zip.NewWriter(w)
+
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"posts.json", data.Posts},
+		{"comments.json", data.Comments},
+		{"sessions.json", data.Sessions},
+		{"api_keys.json", data.APIKeys},
+		{"external_identities.json", data.ExternalIdentities},
+	}
+	for _, file := range files {
+		entry, err := writer.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: data.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeJSON(entry, file.value); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
@@ -0,0 +1,19 @@
+package accounts
+
+import (
+	"log"
+	"time"
+)
+
+// Purge deletes the accounts past their cooling-off period and the expired exports
+func Purge(now time.Time) error {
+	count, err := PurgeDeletedAccounts(now)
+	if count > 0 {
+		log.Printf("Purged %d deleted accounts", count)
+	}
+	if err != nil {
+		return err
+	}
+
+	return DeleteExpiredExports(now)
+}
@@ -0,0 +1,90 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
+)
+
+// Prefix tells API keys apart from JWTs in the Authorization header
+const Prefix = "blog_"
+
+// Scopes an API key can be given, a scope is <resource>:<read|write>
+// Tags are read-only, they are written through the posts
+var Scopes = []string{
+	"posts:read",
+	"posts:write",
+	"categories:read",
+	"categories:write",
+	"tags:read",
+	"comments:read",
+	"comments:write",
+	"users:read",
+	"users:write",
+}
+
+var ErrInvalidKey = errors.New("invalid api key")
+
+// IsAPIKey reports whether the credential looks like an API key
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, Prefix)
+}
+
+// IsValidScope reports whether the scope is one of Scopes
+func IsValidScope(scope string) bool {
+	for _, valid := range Scopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate returns a new key formatted as blog_<prefix>_<secret>
+func Generate() (raw, prefix string, err error) {
+	b := make([]byte, 28)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	encoded := hex.EncodeToString(b)
+
+	prefix = Prefix + encoded[:8]
+	return prefix + "_" + encoded[8:], prefix, nil
+}
+
+// Authenticate finds the active key and records when it was last used
+func Authenticate(raw string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := initializers.DB.Where("key_hash = ? AND revoked_at IS NULL", tokens.HashToken(raw)).First(&key).Error
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, ErrInvalidKey
+	}
+
+	// Writing on every request is not needed, a minute precision is enough
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		initializers.DB.Model(&key).Update("last_used_at", now)
+	}
+
+	return &key, nil
+}
+
+// HasScope reports whether the key was given the scope
+func HasScope(key *models.APIKey, scope string) bool {
+	for _, granted := range key.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
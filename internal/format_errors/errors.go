@@ -30,4 +30,10 @@ func InternalServerError(c *gin.Context) {
 		"error": "Internal server error",
 	})
 	return
-}
\ No newline at end of file
+}
+
+func Forbidden(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error": "You don't have permission to perform this action",
+	})
+}
@@ -4,7 +4,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
 	"github.com/wisnuuakbr/blog-rest-go/api/middleware"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
 )
 
 func GetAuthUser(c *gin.Context) *middleware.AuthUser {
@@ -21,4 +23,30 @@ func GetAuthUser(c *gin.Context) *middleware.AuthUser {
 		return &user
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// GetAuthClaims returns the claims of the access token used for the request
+func GetAuthClaims(c *gin.Context) jwt.MapClaims {
+	claims, exists := c.Get("authClaims")
+	if !exists {
+		return nil
+	}
+
+	if mapClaims, ok := claims.(jwt.MapClaims); ok {
+		return mapClaims
+	}
+	return nil
+}
+
+// GetAuthSession returns the session of the access token, nil for API keys
+func GetAuthSession(c *gin.Context) *models.Session {
+	session, exists := c.Get("authSession")
+	if !exists {
+		return nil
+	}
+
+	if s, ok := session.(*models.Session); ok {
+		return s
+	}
+	return nil
+}
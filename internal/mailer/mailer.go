@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"log"
+	"sync"
+
+	"github.com/wisnuuakbr/blog-rest-go/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails, implement it to plug in a real email provider
+type Mailer interface {
+	Send(message Message) error
+}
+
+var (
+	defaultMailer Mailer
+	once          sync.Once
+)
+
+// SetDefault replaces the mailer used by Send
+func SetDefault(m Mailer) {
+	once.Do(func() {})
+	defaultMailer = m
+}
+
+// Send delivers the message with the mailer configured by MAILER (log or file)
+func Send(message Message) error {
+	once.Do(func() {
+		switch config.GetEnv("MAILER", "log") {
+		case "file":
+			defaultMailer = &FileMailer{Dir: config.GetEnv("MAIL_DIR", "storage/mails")}
+		default:
+			defaultMailer = &LogMailer{}
+		}
+	})
+	return defaultMailer.Send(message)
+}
+
+// LogMailer prints the emails to the server log, for local development
+type LogMailer struct{}
+
+func (m *LogMailer) Send(message Message) error {
+	log.Printf("mail to=%s subject=%q\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
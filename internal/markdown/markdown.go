@@ -0,0 +1,64 @@
+// Package markdown renders CommonMark with the GitHub extensions to sanitized HTML
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// version is part of the hash, bump it when the rendering changes so the
+// cached HTML is rendered again
+const version = "1"
+
+var (
+	renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	// policy is an allowlist, anything else like scripts, styles and event
+	// handlers is removed
+	policy = newPolicy()
+
+	// text keeps the text of the HTML only
+	text = bluemonday.StrictPolicy()
+
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+func newPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	// Language of the fenced code blocks
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	// Task lists
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+	return policy
+}
+
+// Render converts the markdown to sanitized HTML
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
+
+// Plain returns the text of the rendered HTML
+func Plain(renderedHTML string) string {
+	plain := html.UnescapeString(text.Sanitize(renderedHTML))
+	return strings.TrimSpace(blankLines.ReplaceAllString(plain, "\n\n"))
+}
+
+// Hash identifies the source and the renderer, the HTML only needs to be
+// rendered again when it changes
+func Hash(source string) string {
+	sum := sha256.Sum256([]byte(version + "\x00" + source))
+	return hex.EncodeToString(sum[:])
+}
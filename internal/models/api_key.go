@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// APIKey is a personal credential for scripts, only its sha256 hash is stored
+type APIKey struct {
+	ID     uint   `gorm:"primarykey" json:"id"`
+	UserID uint   `gorm:"index;not null" json:"-"`
+	Name   string `gorm:"not null" json:"name"`
+	// Prefix is the start of the key, so users can tell their keys apart
+	Prefix     string     `gorm:"not null" json:"prefix"`
+	KeyHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes     []string   `gorm:"serializer:json" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
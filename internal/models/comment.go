@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment is left on a post, replies point to the comment they answer and
+// to the top-level comment of their thread
+type Comment struct {
+	ID     uint  `gorm:"primarykey" json:"id"`
+	PostID uint  `gorm:"index;not null" json:"post_id"`
+	UserID *uint `gorm:"index" json:"userID"`
+	// Nil once the author is deleted
+	User     *User `gorm:"constraint:OnDelete:SET NULL" json:"user,omitempty"`
+	ParentID *uint `gorm:"index" json:"parent_id"`
+	// RootID is the top-level comment of the thread, nil for top-level comments
+	RootID *uint `gorm:"index" json:"root_id"`
+	// Depth is 0 for top-level comments, 1 for their replies...
+	Depth     int        `gorm:"not null;default:0" json:"depth"`
+	Body      string     `gorm:"type:text;not null" json:"body"`
+	EditedAt  *time.Time `json:"edited_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	// Deleted comments with replies stay in the tree without their body
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	Replies   []Comment      `gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE" json:"replies,omitempty"`
+}
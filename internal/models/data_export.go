@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	ExportPending    = "pending"
+	ExportProcessing = "processing"
+	ExportReady      = "ready"
+	ExportFailed     = "failed"
+)
+
+// DataExport is an archive of everything stored about a user, built in the
+// background by the scheduler
+type DataExport struct {
+	ID     uint   `gorm:"primarykey" json:"id"`
+	UserID uint   `gorm:"index;not null" json:"-"`
+	Format string `gorm:"not null" json:"format"`
+	Status string `gorm:"not null" json:"status"`
+	// Path of the archive on disk, set once the export is ready
+	FilePath  string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	// Set when an instance starts building the archive
+	StartedAt   *time.Time `json:"-"`
+	CompletedAt *time.Time `json:"completed_at"`
+	// The archive is deleted after this moment
+	ExpiresAt *time.Time `json:"expires_at"`
+}
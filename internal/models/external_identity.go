@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ExternalIdentity links an account of an OpenID Connect provider to a user
+type ExternalIdentity struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"index;not null"`
+	User      User   `json:"-"`
+	Provider  string `gorm:"uniqueIndex:idx_provider_subject;not null" json:"provider"`
+	Subject   string `gorm:"uniqueIndex:idx_provider_subject;not null" json:"subject"`
+	Email     string `json:"email"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginAttempt counts the failed logins of an email or IP for the database throttle store
+type LoginAttempt struct {
+	ID          uint   `gorm:"primarykey"`
+	Key         string `gorm:"uniqueIndex;not null"`
+	Failures    int    `gorm:"not null;default:0"`
+	LockedUntil *time.Time
+	UpdatedAt   time.Time
+}
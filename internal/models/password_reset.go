@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PasswordReset only keeps the sha256 hash of the token sent by email
+type PasswordReset struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
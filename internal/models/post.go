@@ -1,10 +1,115 @@
 package models
 
+import (
+	"errors"
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/internal/markdown"
+	"gorm.io/gorm"
+)
+
+const (
+	PostDraft     = "draft"
+	PostPublished = "published"
+	PostArchived  = "archived"
+)
+
+// postTransitions lists the statuses a post can move to from each status
+var postTransitions = map[string][]string{
+	PostDraft:     {PostPublished},
+	PostPublished: {PostDraft, PostArchived},
+	PostArchived:  {PostPublished},
+}
+
+var ErrInvalidTransition = errors.New("invalid status transition")
+
 type Post struct {
-	ID         uint   `gorm:"primarykey"`
-	Title      string `gorm:"not null" json:"title"`
+	ID    uint   `gorm:"primarykey"`
+	Title string `gorm:"not null" json:"title"`
+	// Made from the title, unique among the current and old slugs of every post
+	Slug string `gorm:"unique;not null" json:"slug"`
+	// Markdown source, BodyHTML is its sanitized rendering and BodyHash tells
+	// if it is up to date
 	Body       string `gorm:"type:text" json:"body"`
+	BodyHTML   string `gorm:"type:text" json:"-"`
+	BodyHash   string `json:"-"`
 	UserID     uint   `gorm:"foreignkey:UserID" json:"userID"`
 	User       User   `gorm:"foreignkey:UserID"`
 	CategoryID uint   `gorm:"foreignkey:CategoryID" json:"categoryID"`
-}
\ No newline at end of file
+	Tags       []Tag  `gorm:"many2many:post_tags;constraint:OnDelete:CASCADE" json:"tags"`
+	// Drafts are only visible to their author and editors
+	Status string `gorm:"type:varchar(20);not null;default:draft;index" json:"status"`
+	// Set the first time the post is published, kept when it is archived
+	PublishedAt *time.Time `json:"published_at"`
+	// A draft with a publish time is published by the scheduler once it is due
+	PublishAt *time.Time `gorm:"index" json:"publish_at"`
+	// Only the author and editors can comment a locked post
+	CommentsLocked bool `gorm:"not null;default:false" json:"comments_locked"`
+	// How many revisions are kept, nil uses POST_REVISION_LIMIT and 0 keeps them all
+	RevisionLimit *int `json:"revision_limit"`
+	// Revisions go away with the post when it is deleted permanently
+	Revisions []PostRevision `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Slugs     []PostSlug     `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Comments  []Comment      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (post *Post) BeforeCreate(tx *gorm.DB) (err error) {
+	if err = post.Render(); err != nil {
+		return
+	}
+	post.Slug, err = uniquePostSlug(tx, post.Title, 0)
+	return
+}
+
+func (post *Post) AfterCreate(tx *gorm.DB) (err error) {
+	return tx.Create(&PostSlug{PostID: post.ID, Slug: post.Slug}).Error
+}
+
+// Render renders the body to HTML, unless it hasn't changed since the last
+// time. It doesn't save the post.
+func (post *Post) Render() error {
+	hash := markdown.Hash(post.Body)
+	if post.BodyHash == hash {
+		return nil
+	}
+
+	html, err := markdown.Render(post.Body)
+	if err != nil {
+		return err
+	}
+	post.BodyHTML = html
+	post.BodyHash = hash
+	return nil
+}
+
+// Transition moves the post to the status, it doesn't save the post
+func (post *Post) Transition(status string, now time.Time) error {
+	if post.Status == "" {
+		post.Status = PostDraft
+	}
+
+	allowed := false
+	for _, next := range postTransitions[post.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return ErrInvalidTransition
+	}
+
+	// A post that changes status by hand is not scheduled anymore
+	post.Status = status
+	post.PublishAt = nil
+	switch status {
+	case PostPublished:
+		if post.PublishedAt == nil {
+			post.PublishedAt = &now
+		}
+	case PostDraft:
+		post.PublishedAt = nil
+	}
+	return nil
+}
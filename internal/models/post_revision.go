@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PostRevision is a copy of the title and body of a post, written on every
+// change and never updated
+type PostRevision struct {
+	ID     uint `gorm:"primarykey" json:"id"`
+	PostID uint `gorm:"uniqueIndex:idx_post_revision;not null" json:"post_id"`
+	// Number counts the revisions of the post from 1
+	Number int    `gorm:"uniqueIndex:idx_post_revision;not null" json:"number"`
+	Title  string `gorm:"not null" json:"title"`
+	Body   string `gorm:"type:text" json:"body"`
+	// Nil once the author of the revision is deleted
+	UserID    *uint     `gorm:"index" json:"userID"`
+	User      *User     `gorm:"constraint:OnDelete:SET NULL" json:"user,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+)
+
+// PostSlug is every slug a post had, old slugs redirect to the current one
+type PostSlug struct {
+	ID        uint   `gorm:"primarykey"`
+	PostID    uint   `gorm:"index;not null"`
+	Slug      string `gorm:"unique;not null"`
+	CreatedAt time.Time
+}
+
+// Reslug gives the post the slug of its new title, the old slug keeps
+// pointing to the post
+func (post *Post) Reslug(tx *gorm.DB) error {
+	newSlug, err := uniquePostSlug(tx, post.Title, post.ID)
+	if err != nil {
+		return err
+	}
+	if newSlug == post.Slug {
+		return nil
+	}
+
+	if err := tx.Model(post).Update("slug", newSlug).Error; err != nil {
+		return err
+	}
+	post.Slug = newSlug
+
+	// Going back to an old title reuses its slug
+	return tx.Where(PostSlug{PostID: post.ID, Slug: newSlug}).FirstOrCreate(&PostSlug{}).Error
+}
+
+// uniquePostSlug makes the slug of the title, adding -2, -3... when another
+// post has or had it
+func uniquePostSlug(tx *gorm.DB, title string, postID uint) (string, error) {
+	base := slug.Make(title)
+	if base == "" {
+		base = "post"
+	}
+
+	for i := 1; ; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+
+		var taken []PostSlug
+		if err := tx.Where("slug = ?", candidate).Limit(1).Find(&taken).Error; err != nil {
+			return "", err
+		}
+		if len(taken) == 0 || (postID != 0 && taken[0].PostID == postID) {
+			return candidate, nil
+		}
+	}
+}
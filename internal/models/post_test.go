@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPostTransitionMatrix(t *testing.T) {
+	statuses := []string{PostDraft, PostPublished, PostArchived}
+	allowed := map[[2]string]bool{
+		{PostDraft, PostPublished}:    true,
+		{PostPublished, PostDraft}:    true,
+		{PostPublished, PostArchived}: true,
+		{PostArchived, PostPublished}: true,
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, from := range statuses {
+		for _, to := range statuses {
+			post := Post{Status: from}
+			err := post.Transition(to, now)
+
+			if allowed[[2]string{from, to}] {
+				if err != nil {
+					t.Errorf("%s -> %s: unexpected error %v", from, to, err)
+				}
+				if post.Status != to {
+					t.Errorf("%s -> %s: status is %s", from, to, post.Status)
+				}
+				continue
+			}
+
+			if !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("%s -> %s: error is %v, want ErrInvalidTransition", from, to, err)
+			}
+			if post.Status != from {
+				t.Errorf("%s -> %s: status changed to %s", from, to, post.Status)
+			}
+		}
+	}
+}
+
+func TestPostTransitionUnknownStatus(t *testing.T) {
+	post := Post{Status: PostDraft}
+	if err := post.Transition("deleted", time.Now()); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("error is %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestPostTransitionEmptyStatusIsDraft(t *testing.T) {
+	var post Post
+	if err := post.Transition(PostPublished, time.Now()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := (&Post{}).Transition(PostArchived, time.Now()); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("a new post was archived, error is %v", err)
+	}
+}
+
+func TestPostTransitionDates(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	earlier := now.Add(-24 * time.Hour)
+	later := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name        string
+		post        Post
+		to          string
+		publishedAt *time.Time
+	}{
+		{"first publication", Post{Status: PostDraft}, PostPublished, &now},
+		{"scheduled draft published by hand", Post{Status: PostDraft, PublishAt: &later}, PostPublished, &now},
+		{"republished archive keeps its date", Post{Status: PostArchived, PublishedAt: &earlier}, PostPublished, &earlier},
+		{"archive keeps the date", Post{Status: PostPublished, PublishedAt: &earlier}, PostArchived, &earlier},
+		{"unpublish clears the date", Post{Status: PostPublished, PublishedAt: &earlier}, PostDraft, nil},
+	}
+
+	for _, test := range tests {
+		post := test.post
+		if err := post.Transition(test.to, now); err != nil {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+
+		if post.PublishAt != nil {
+			t.Errorf("%s: the post is still scheduled for %v", test.name, post.PublishAt)
+		}
+		switch {
+		case test.publishedAt == nil && post.PublishedAt != nil:
+			t.Errorf("%s: published at %v, want nil", test.name, post.PublishedAt)
+		case test.publishedAt != nil && (post.PublishedAt == nil || !post.PublishedAt.Equal(*test.publishedAt)):
+			t.Errorf("%s: published at %v, want %v", test.name, post.PublishedAt, test.publishedAt)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RecoveryCode replaces a TOTP code once, only its sha256 hash is stored
+type RecoveryCode struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RefreshToken only keeps the sha256 hash of the opaque token handed to the
+// client. Tokens created from each other by rotation share the same FamilyID.
+type RefreshToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	FamilyID  string    `gorm:"index;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RevokedToken is a JWT id that must be rejected until the token expires
+type RevokedToken struct {
+	ID        uint      `gorm:"primarykey"`
+	JTI       string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,47 @@
+package models
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+
+	PermUsersManage          = "users:manage"
+	PermUsersDeletePermanent = "users:delete:permanent"
+	PermPostsUpdateAny       = "posts:update:any"
+	PermPostsDeleteAny       = "posts:delete:any"
+)
+
+// DefaultRolePermissions is seeded by the migration
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermUsersManage,
+		PermUsersDeletePermanent,
+		PermPostsUpdateAny,
+		PermPostsDeleteAny,
+	},
+	RoleEditor: {
+		PermPostsUpdateAny,
+		PermPostsDeleteAny,
+	},
+	RoleAuthor: {},
+}
+
+type Role struct {
+	ID          uint         `gorm:"primarykey"`
+	Name        string       `gorm:"unique;not null" json:"name"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+}
+
+type Permission struct {
+	ID   uint   `gorm:"primarykey"`
+	Name string `gorm:"unique;not null" json:"name"`
+}
+
+// PermissionNames returns the names of the role permissions
+func (role *Role) PermissionNames() []string {
+	names := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		names = append(names, permission.Name)
+	}
+	return names
+}
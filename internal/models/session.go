@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Session is created by every successful login, its refresh tokens share the FamilyID
+type Session struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	FamilyID   string     `gorm:"uniqueIndex;not null" json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tag is a free-form label, posts can have many of them. Tags are told
+// apart by their slug, so "Go" and "go" are the same tag.
+type Tag struct {
+	ID    uint   `gorm:"primarykey" json:"id"`
+	Name  string `gorm:"not null" json:"name"`
+	Slug  string `gorm:"unique;not null" json:"slug"`
+	Posts []Post `gorm:"many2many:post_tags;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (tag *Tag) BeforeCreate(tx *gorm.DB) (err error) {
+	tag.Slug = slug.Make(tag.Name)
+
+	return
+}
+
+// FindOrCreateTags returns the tags of the names, the unknown ones are created
+func FindOrCreateTags(tx *gorm.DB, names []string) ([]Tag, error) {
+	tags := make([]Tag, 0, len(names))
+	slugs := make([]string, 0, len(names))
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		tagSlug := slug.Make(name)
+		if tagSlug == "" || seen[tagSlug] {
+			continue
+		}
+		seen[tagSlug] = true
+
+		tags = append(tags, Tag{Name: name})
+		slugs = append(slugs, tagSlug)
+	}
+	if len(tags) == 0 {
+		return tags, nil
+	}
+
+	// Tags created in the meantime by someone else are kept as they are
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var found []Tag
+	if err := tx.Where("slug IN ?", slugs).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	return found, nil
+}
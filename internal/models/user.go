@@ -1,9 +1,30 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type User struct {
 	ID       uint   `gorm:"primaryKey"`
 	Name     string `json:"name"`
 	Email    string `json:"email" gorm:"unique;not null"`
 	Password string `json:"-"`
-	Posts    []Post
-}
\ No newline at end of file
+	// Nil until the user opens the verification link
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	RoleID          *uint          `json:"roleID"`
+	Role            *Role          `json:"role,omitempty"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	// TOTP second factor, the secret is set on setup and enabled once the first code is verified
+	TOTPSecret    string     `json:"-"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	// Time step of the last accepted code, a code can't be used twice
+	TOTPLastStep int64 `json:"-"`
+	// Access tokens issued before this moment are rejected
+	TokensRevokedAt *time.Time `json:"-"`
+	// Set when the user asks to delete the account, the account and its
+	// posts are deleted permanently once this moment has passed
+	DeletionScheduledAt *time.Time `gorm:"index" json:"deletion_scheduled_at"`
+	Posts               []Post
+}
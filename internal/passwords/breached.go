@@ -0,0 +1,83 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The breached list uses the k-anonymity format of the Pwned Passwords range
+// API: the upper case SHA-1 of each password is split into a 5 characters
+// prefix and the suffix, one "PREFIX:SUFFIX" per line. Lines may end with
+// ":COUNT", and lines starting with # are comments.
+
+const prefixLength = 5
+
+var (
+	mu    sync.Mutex
+	lists = map[string]map[string]map[string]bool{}
+)
+
+// IsBreached reports whether the password is in the list of the file
+func IsBreached(file, password string) (bool, error) {
+	list, err := loadList(file)
+	if err != nil {
+		return false, err
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	return list[hash[:prefixLength]][hash[prefixLength:]], nil
+}
+
+// HashPrefix returns the line of the password in the list format
+func HashPrefix(password string) string {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:prefixLength] + ":" + hash[prefixLength:]
+}
+
+// loadList reads the file once and indexes the suffixes by prefix
+func loadList(file string) (map[string]map[string]bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if list, ok := lists[file]; ok {
+		return list, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := map[string]map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(strings.ToUpper(line), ":")
+		if len(parts) < 2 || len(parts[0]) != prefixLength {
+			continue
+		}
+
+		if list[parts[0]] == nil {
+			list[parts[0]] = map[string]bool{}
+		}
+		list[parts[0]][parts[1]] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	lists[file] = list
+	return list, nil
+}
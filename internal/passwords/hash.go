@@ -0,0 +1,156 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashes are self-describing, so the algorithm and its parameters can change
+// without breaking the stored hashes:
+//
+//	bcrypt:   $2a$<cost>$<salt and hash>
+//	argon2id: $argon2id$v=19$m=<memory KiB>,t=<time>,p=<threads>$<salt>$<hash>
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+type HashParams struct {
+	Algorithm  string
+	BcryptCost int
+	// argon2id parameters, see RFC 9106 section 4
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultHashParams is configured by the PASSWORD_HASH_* env variables
+func DefaultHashParams() HashParams {
+	return HashParams{
+		Algorithm:  config.GetEnv("PASSWORD_HASH_ALGORITHM", Argon2id),
+		BcryptCost: config.GetInt("PASSWORD_HASH_BCRYPT_COST", 12),
+		Memory:     uint32(config.GetInt("PASSWORD_HASH_ARGON2_MEMORY", 64*1024)),
+		Time:       uint32(config.GetInt("PASSWORD_HASH_ARGON2_TIME", 3)),
+		Threads:    uint8(config.GetInt("PASSWORD_HASH_ARGON2_THREADS", 2)),
+		SaltLen:    16,
+		KeyLen:     32,
+	}
+}
+
+// Hash hashes the password with the default parameters
+func Hash(password string) (string, error) {
+	return DefaultHashParams().Hash(password)
+}
+
+// Verify compares the password with a hash of any supported format
+func Verify(password, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+
+	case strings.HasPrefix(encoded, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	return false, ErrUnknownHash
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or
+// weaker parameters than the default ones
+func NeedsRehash(encoded string) bool {
+	return DefaultHashParams().NeedsRehash(encoded)
+}
+
+func (params HashParams) Hash(password string) (string, error) {
+	switch params.Algorithm {
+	case Argon2id:
+		salt := make([]byte, params.SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, params.Memory, params.Time, params.Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+
+	case Bcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), params.BcryptCost)
+		return string(hash), err
+	}
+
+	return "", fmt.Errorf("unknown password hash algorithm: %s", params.Algorithm)
+}
+
+func (params HashParams) NeedsRehash(encoded string) bool {
+	switch params.Algorithm {
+	case Argon2id:
+		current, _, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return true
+		}
+		return current.Memory != params.Memory || current.Time != params.Time ||
+			current.Threads != params.Threads || uint32(len(key)) != params.KeyLen
+
+	case Bcrypt:
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != params.BcryptCost
+	}
+
+	return false
+}
+
+func decodeArgon2id(encoded string) (HashParams, []byte, []byte, error) {
+	params := HashParams{Algorithm: Argon2id}
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,142 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Cheap parameters, the tests only check the format and the comparison
+var (
+	argon2Params = HashParams{Algorithm: Argon2id, Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+	bcryptParams = HashParams{Algorithm: Bcrypt, BcryptCost: 4}
+)
+
+func TestHashVerifyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		params HashParams
+		prefix string
+	}{
+		{"argon2id", argon2Params, "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{"bcrypt", bcryptParams, "$2a$04$"},
+	}
+
+	for _, test := range tests {
+		encoded, err := test.params.Hash("correct horse battery staple")
+		if err != nil {
+			t.Fatalf("%s: Hash: %v", test.name, err)
+		}
+		if !strings.HasPrefix(encoded, test.prefix) {
+			t.Errorf("%s: Hash = %s, want the prefix %s", test.name, encoded, test.prefix)
+		}
+
+		if valid, err := Verify("correct horse battery staple", encoded); err != nil || !valid {
+			t.Errorf("%s: Verify of the right password = %v, %v", test.name, valid, err)
+		}
+		if valid, err := Verify("correct horse battery stapler", encoded); err != nil || valid {
+			t.Errorf("%s: Verify of a wrong password = %v, %v", test.name, valid, err)
+		}
+	}
+}
+
+func TestHashUsesRandomSalt(t *testing.T) {
+	first, _ := argon2Params.Hash("password")
+	second, _ := argon2Params.Hash("password")
+	if first == second {
+		t.Error("two hashes of the same password are equal")
+	}
+}
+
+func TestHashUnknownAlgorithm(t *testing.T) {
+	if _, err := (HashParams{Algorithm: "md5"}).Hash("password"); err == nil {
+		t.Error("Hash accepted an unknown algorithm")
+	}
+}
+
+func TestVerifyMalformedHashes(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"plain text", "password"},
+		{"other algorithm", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{"missing key", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA"},
+		{"unknown version", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{"bad parameters", "$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5"},
+		{"bad salt", "$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5"},
+		{"bad key", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$!!!"},
+	}
+
+	for _, test := range tests {
+		valid, err := Verify("password", test.encoded)
+		if valid || !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("%s: Verify = %v, %v, want ErrUnknownHash", test.name, valid, err)
+		}
+	}
+}
+
+func TestDecodeArgon2id(t *testing.T) {
+	encoded, err := argon2Params.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params != argon2Params {
+		t.Errorf("params = %+v, want %+v", params, argon2Params)
+	}
+	if len(salt) != 16 || len(key) != 32 {
+		t.Errorf("salt and key have %d and %d bytes, want 16 and 32", len(salt), len(key))
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon2Hash, err := argon2Params.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bcryptParams.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withMemory := argon2Params
+	withMemory.Memory = 2048
+	withTime := argon2Params
+	withTime.Time = 2
+	withThreads := argon2Params
+	withThreads.Threads = 2
+	withKeyLen := argon2Params
+	withKeyLen.KeyLen = 64
+	withCost := bcryptParams
+	withCost.BcryptCost = 5
+
+	tests := []struct {
+		name    string
+		params  HashParams
+		encoded string
+		want    bool
+	}{
+		{"same argon2id parameters", argon2Params, argon2Hash, false},
+		{"more argon2id memory", withMemory, argon2Hash, true},
+		{"more argon2id time", withTime, argon2Hash, true},
+		{"more argon2id threads", withThreads, argon2Hash, true},
+		{"longer argon2id key", withKeyLen, argon2Hash, true},
+		{"bcrypt to argon2id", argon2Params, bcryptHash, true},
+		{"same bcrypt cost", bcryptParams, bcryptHash, false},
+		{"higher bcrypt cost", withCost, bcryptHash, true},
+		{"argon2id to bcrypt", bcryptParams, argon2Hash, true},
+		{"malformed hash", argon2Params, "password", true},
+	}
+
+	for _, test := range tests {
+		if got := test.params.NeedsRehash(test.encoded); got != test.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
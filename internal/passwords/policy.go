@@ -0,0 +1,76 @@
+package passwords
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"unicode/utf8"
+
+	"github.com/nbutton23/zxcvbn-go"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+)
+
+// Policy is checked on every new password
+type Policy struct {
+	MinLength int
+	// MaxLength bounds the work of the strength check, zxcvbn gets very slow on long inputs
+	MaxLength int
+	// MinScore is the zxcvbn score from 0 (too guessable) to 4 (very unguessable)
+	MinScore int
+	// BreachedFile is the hash-prefix list of breached passwords, empty to skip the check
+	BreachedFile string
+}
+
+// DefaultPolicy is configured by the PASSWORD_* env variables
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength:    config.GetInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:    config.GetInt("PASSWORD_MAX_LENGTH", 128),
+		MinScore:     config.GetInt("PASSWORD_MIN_SCORE", 2),
+		BreachedFile: breachedFile(),
+	}
+}
+
+// breachedFile defaults to the list shipped with the repo, setting
+// PASSWORD_BREACHED_FILE to an empty value turns the check off
+func breachedFile() string {
+	if file, ok := os.LookupEnv("PASSWORD_BREACHED_FILE"); ok {
+		return file
+	}
+	return "data/breached_passwords.txt"
+}
+
+// Validate returns the violations keyed by field, like validations.FormatValidationErrors.
+// userInputs (name, email...) make passwords based on them weaker.
+func (policy Policy) Validate(field, password string, userInputs ...string) map[string]string {
+	errorMessages := make(map[string]string)
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		errorMessages[field] = fmt.Sprintf("%s must have at least %d characters", field, policy.MinLength)
+		return errorMessages
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		errorMessages[field] = fmt.Sprintf("%s must have at most %d characters", field, policy.MaxLength)
+		return errorMessages
+	}
+
+	if policy.BreachedFile != "" {
+		breached, err := IsBreached(policy.BreachedFile, password)
+		if err != nil {
+			// A missing list must not block every registration
+			log.Println("Failed to check the breached passwords:", err)
+		}
+		if breached {
+			errorMessages[field] = fmt.Sprintf("%s has appeared in a data breach, please choose another one", field)
+			return errorMessages
+		}
+	}
+
+	strength := zxcvbn.PasswordStrength(password, userInputs)
+	if strength.Score < policy.MinScore {
+		errorMessages[field] = fmt.Sprintf("%s is too weak, add more words or characters", field)
+	}
+
+	return errorMessages
+}
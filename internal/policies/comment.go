@@ -0,0 +1,23 @@
+package policies
+
+import (
+	"github.com/wisnuuakbr/blog-rest-go/api/middleware"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+)
+
+// CanComment allows anyone on an unlocked post, and the users who can update
+// the post on a locked one
+func CanComment(authUser *middleware.AuthUser, post *models.Post) bool {
+	return authUser != nil && (!post.CommentsLocked || CanUpdatePost(authUser, post))
+}
+
+// CanEditComment only allows the author of the comment
+func CanEditComment(authUser *middleware.AuthUser, comment *models.Comment) bool {
+	return authUser != nil && comment.UserID != nil && *comment.UserID == authUser.ID
+}
+
+// CanDeleteComment allows the author of the comment, the author of the post
+// and the users who can delete any post
+func CanDeleteComment(authUser *middleware.AuthUser, comment *models.Comment, post *models.Post) bool {
+	return CanEditComment(authUser, comment) || isAuthor(authUser, post) || authUser.Can(models.PermPostsDeleteAny)
+}
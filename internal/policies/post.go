@@ -0,0 +1,39 @@
+package policies
+
+import (
+	"github.com/wisnuuakbr/blog-rest-go/api/middleware"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// CanViewPost hides drafts from everyone but the author and editors
+func CanViewPost(authUser *middleware.AuthUser, post *models.Post) bool {
+	return post.Status != models.PostDraft || isAuthor(authUser, post) || authUser.Can(models.PermPostsUpdateAny)
+}
+
+// VisiblePosts is the query scope of CanViewPost
+func VisiblePosts(authUser *middleware.AuthUser) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if authUser.Can(models.PermPostsUpdateAny) {
+			return db
+		}
+		if authUser == nil {
+			return db.Where("posts.status <> ?", models.PostDraft)
+		}
+		return db.Where("(posts.status <> ? OR posts.user_id = ?)", models.PostDraft, authUser.ID)
+	}
+}
+
+// CanUpdatePost allows the author or a user who can update any post
+func CanUpdatePost(authUser *middleware.AuthUser, post *models.Post) bool {
+	return isAuthor(authUser, post) || authUser.Can(models.PermPostsUpdateAny)
+}
+
+// CanDeletePost allows the author or a user who can delete any post
+func CanDeletePost(authUser *middleware.AuthUser, post *models.Post) bool {
+	return isAuthor(authUser, post) || authUser.Can(models.PermPostsDeleteAny)
+}
+
+func isAuthor(authUser *middleware.AuthUser, post *models.Post) bool {
+	return authUser != nil && post.UserID == authUser.ID
+}
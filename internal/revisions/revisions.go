@@ -0,0 +1,102 @@
+// Package revisions keeps the history of the title and body of posts
+package revisions
+
+import (
+	"fmt"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Limit is how many revisions of the post are kept, 0 keeps them all
+func Limit(post *models.Post) int {
+	if post.RevisionLimit != nil {
+		return *post.RevisionLimit
+	}
+	return config.GetInt("POST_REVISION_LIMIT", 50)
+}
+
+// Record writes the current title and body of the post as its next revision
+// and prunes the oldest revisions over the limit. Call it in the transaction
+// that saves the post.
+func Record(tx *gorm.DB, post *models.Post, userID uint) (*models.PostRevision, error) {
+	// Lock the post so concurrent updates don't get the same number
+	var locked models.Post
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, post.ID).Error; err != nil {
+		return nil, err
+	}
+
+	var last int
+	err := tx.Model(&models.PostRevision{}).Where("post_id = ?", post.ID).Select("COALESCE(MAX(number), 0)").Scan(&last).Error
+	if err != nil {
+		return nil, err
+	}
+
+	revision := models.PostRevision{
+		PostID: post.ID,
+		Number: last + 1,
+		Title:  post.Title,
+		Body:   post.Body,
+		UserID: &userID,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, err
+	}
+
+	if err := Prune(tx, post); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// Baseline records the content of a post written before revisions existed,
+// as a revision of its author
+func Baseline(tx *gorm.DB, post *models.Post) error {
+	var count int64
+	if err := tx.Model(&models.PostRevision{}).Where("post_id = ?", post.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err := Record(tx, post, post.UserID)
+	return err
+}
+
+// Prune deletes the oldest revisions over the limit of the post
+func Prune(tx *gorm.DB, post *models.Post) error {
+	limit := Limit(post)
+	if limit <= 0 {
+		return nil
+	}
+
+	var last int
+	err := tx.Model(&models.PostRevision{}).Where("post_id = ?", post.ID).Select("COALESCE(MAX(number), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Where("post_id = ? AND number <= ?", post.ID, last-limit).Delete(&models.PostRevision{}).Error
+}
+
+// Diff returns the unified diff from one revision to the other, the title
+// is the first line of each side
+func Diff(from, to *models.PostRevision) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(content(from)),
+		B:        difflib.SplitLines(content(to)),
+		FromFile: fmt.Sprintf("revision %d", from.Number),
+		ToFile:   fmt.Sprintf("revision %d", to.Number),
+		FromDate: from.CreatedAt.Format("2006-01-02 15:04:05"),
+		ToDate:   to.CreatedAt.Format("2006-01-02 15:04:05"),
+		Context:  3,
+	})
+}
+
+func content(revision *models.PostRevision) string {
+	return revision.Title + "\n\n" + revision.Body
+}
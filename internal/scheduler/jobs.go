@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/internal/accounts"
+)
+
+// Default returns the scheduler with every job of the API
+func Default() *Scheduler {
+	return New(
+		Job{
+			Name:     "publish scheduled posts",
+			Interval: config.GetDuration("SCHEDULER_PUBLISH_INTERVAL", 30*time.Second),
+			Run:      PublishDuePosts,
+		},
+		Job{
+			Name:     "build data exports",
+			Interval: config.GetDuration("SCHEDULER_EXPORT_INTERVAL", 15*time.Second),
+			Run:      accounts.BuildPendingExports,
+		},
+		Job{
+			Name:     "purge deleted accounts",
+			Interval: config.GetDuration("ACCOUNT_PURGE_INTERVAL", time.Hour),
+			Run: func(ctx context.Context, now time.Time) error {
+				return accounts.Purge(now)
+			},
+		},
+	)
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// publishBatch is how many posts are published in one transaction
+const publishBatch = 100
+
+// PublishDuePosts publishes the scheduled drafts whose time has come. The rows
+// are locked with SKIP LOCKED, so each post is published by one instance only.
+func PublishDuePosts(ctx context.Context, now time.Time) error {
+	for ctx.Err() == nil {
+		count, err := publishDueBatch(ctx, now)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Printf("Published %d scheduled posts", count)
+		}
+		if count < publishBatch {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+func publishDueBatch(ctx context.Context, now time.Time) (int, error) {
+	var posts []models.Post
+
+	err := initializers.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND publish_at <= ?", models.PostDraft, now).
+			Order("publish_at").
+			Limit(publishBatch).
+			Find(&posts).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range posts {
+			post := &posts[i]
+			// The post is published at the time it was scheduled for
+			if err := post.Transition(models.PostPublished, *post.PublishAt); err != nil {
+				return err
+			}
+			if err := tx.Model(post).Select("status", "published_at", "publish_at").Updates(post).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(posts), nil
+}
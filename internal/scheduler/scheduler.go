@@ -0,0 +1,59 @@
+// Package scheduler runs background jobs inside the API process
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job runs every interval, several instances of the API may run it at the
+// same time so it has to lock what it works on
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context, now time.Time) error
+}
+
+type Scheduler struct {
+	jobs []Job
+	wg   sync.WaitGroup
+}
+
+func New(jobs ...Job) *Scheduler {
+	return &Scheduler{jobs: jobs}
+}
+
+// Start runs every job in its own goroutine until the context is cancelled
+func (scheduler *Scheduler) Start(ctx context.Context) {
+	for _, job := range scheduler.jobs {
+		scheduler.wg.Add(1)
+		go func(job Job) {
+			defer scheduler.wg.Done()
+			run(ctx, job)
+		}(job)
+	}
+}
+
+// Wait blocks until the running jobs are done, after the context is cancelled
+func (scheduler *Scheduler) Wait() {
+	scheduler.wg.Wait()
+}
+
+func run(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("Error running %s: %v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
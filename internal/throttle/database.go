@@ -0,0 +1,67 @@
+package throttle
+
+import (
+	"errors"
+
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBStore shares the attempts between every instance of the API
+type DBStore struct {
+	DB *gorm.DB
+}
+
+var _ Store = (*DBStore)(nil)
+
+func (s *DBStore) Get(key string) (Attempt, error) {
+	var row models.LoginAttempt
+	err := s.DB.Where("key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Attempt{}, nil
+	}
+	if err != nil {
+		return Attempt{}, err
+	}
+	return toAttempt(row), nil
+}
+
+func (s *DBStore) RecordFailure(key string, next func(Attempt) Attempt) (Attempt, error) {
+	var attempt Attempt
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, then lock it so concurrent failures are all counted
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginAttempt{Key: key}).Error
+		if err != nil {
+			return err
+		}
+
+		var row models.LoginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		attempt = next(toAttempt(row))
+		row.Failures = attempt.Failures
+		row.LockedUntil = nil
+		if !attempt.LockedUntil.IsZero() {
+			row.LockedUntil = &attempt.LockedUntil
+		}
+		return tx.Save(&row).Error
+	})
+
+	return attempt, err
+}
+
+func (s *DBStore) Reset(key string) error {
+	return s.DB.Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+func toAttempt(row models.LoginAttempt) Attempt {
+	attempt := Attempt{Failures: row.Failures}
+	if row.LockedUntil != nil {
+		attempt.LockedUntil = *row.LockedUntil
+	}
+	return attempt
+}
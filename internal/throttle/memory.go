@@ -0,0 +1,58 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the attempts in the process, use DBStore when running several instances
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempt
+	touched  map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		attempts: map[string]Attempt{},
+		touched:  map[string]time.Time{},
+	}
+}
+
+func (s *MemoryStore) Get(key string) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.attempts[key], nil
+}
+
+func (s *MemoryStore) RecordFailure(key string, next func(Attempt) Attempt) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanup()
+	attempt := next(s.attempts[key])
+	s.attempts[key] = attempt
+	s.touched[key] = time.Now()
+	return attempt, nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	delete(s.touched, key)
+	return nil
+}
+
+// cleanup forgets the keys without a failure for a day, so the map doesn't grow forever
+func (s *MemoryStore) cleanup() {
+	expired := time.Now().Add(-24 * time.Hour)
+	for key, touched := range s.touched {
+		if touched.Before(expired) && time.Now().After(s.attempts[key].LockedUntil) {
+			delete(s.attempts, key)
+			delete(s.touched, key)
+		}
+	}
+}
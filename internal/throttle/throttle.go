@@ -0,0 +1,125 @@
+// Package throttle tracks failed login attempts. Every failure after the free
+// attempts doubles the wait before the next try, and reaching the maximum
+// locks the key for the lockout duration.
+package throttle
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+)
+
+type Attempt struct {
+	Failures    int
+	LockedUntil time.Time
+}
+
+// Store keeps the attempts by key, implementations must be safe for concurrent use
+type Store interface {
+	Get(key string) (Attempt, error)
+	// RecordFailure increments the failures and sets the lock computed by next
+	RecordFailure(key string, next func(Attempt) Attempt) (Attempt, error)
+	Reset(key string) error
+}
+
+type Policy struct {
+	FreeAttempts int
+	BackoffBase  time.Duration
+	MaxAttempts  int
+	Lockout      time.Duration
+}
+
+var (
+	defaultStore Store
+	once         sync.Once
+)
+
+// DefaultStore returns the store configured by LOGIN_ATTEMPTS_STORE (memory or database)
+func DefaultStore() Store {
+	once.Do(func() {
+		if config.GetEnv("LOGIN_ATTEMPTS_STORE", "memory") == "database" {
+			defaultStore = &DBStore{DB: initializers.DB}
+			return
+		}
+		defaultStore = NewMemoryStore()
+	})
+	return defaultStore
+}
+
+// EmailPolicy limits the attempts on one account
+func EmailPolicy() Policy {
+	return Policy{
+		FreeAttempts: config.GetInt("LOGIN_FREE_ATTEMPTS", 3),
+		BackoffBase:  config.GetDuration("LOGIN_BACKOFF_BASE", time.Second),
+		MaxAttempts:  config.GetInt("LOGIN_MAX_ATTEMPTS", 10),
+		Lockout:      config.GetDuration("LOGIN_LOCKOUT", 15*time.Minute),
+	}
+}
+
+// IPPolicy limits the attempts from one IP, which may be shared by many users
+func IPPolicy() Policy {
+	policy := EmailPolicy()
+	policy.FreeAttempts = config.GetInt("LOGIN_FREE_ATTEMPTS_PER_IP", 20)
+	policy.MaxAttempts = config.GetInt("LOGIN_MAX_ATTEMPTS_PER_IP", 100)
+	return policy
+}
+
+func EmailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// RetryAfter returns how long the key must wait, zero when it is not locked
+func RetryAfter(store Store, key string, now time.Time) (time.Duration, error) {
+	attempt, err := store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if now.Before(attempt.LockedUntil) {
+		return attempt.LockedUntil.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// Fail records a failure of the key and returns the new attempt
+func Fail(store Store, key string, policy Policy, now time.Time) (Attempt, error) {
+	return store.RecordFailure(key, func(attempt Attempt) Attempt {
+		return policy.next(attempt, now)
+	})
+}
+
+// next counts one more failure. A lockout that is over starts the count
+// again, otherwise every later failure would lock the key for good.
+func (policy Policy) next(attempt Attempt, now time.Time) Attempt {
+	if attempt.Failures >= policy.MaxAttempts && !now.Before(attempt.LockedUntil) {
+		attempt.Failures = 0
+	}
+
+	attempt.Failures++
+	attempt.LockedUntil = policy.lockedUntil(attempt.Failures, now)
+	return attempt
+}
+
+func (policy Policy) lockedUntil(failures int, now time.Time) time.Time {
+	if failures >= policy.MaxAttempts {
+		return now.Add(policy.Lockout)
+	}
+	if failures <= policy.FreeAttempts {
+		return time.Time{}
+	}
+
+	backoff := policy.BackoffBase
+	for i := policy.FreeAttempts + 1; i < failures && backoff < policy.Lockout; i++ {
+		backoff *= 2
+	}
+	if backoff > policy.Lockout {
+		backoff = policy.Lockout
+	}
+	return now.Add(backoff)
+}
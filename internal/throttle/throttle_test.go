@@ -0,0 +1,132 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts: 3,
+	BackoffBase:  time.Second,
+	MaxAttempts:  10,
+	Lockout:      15 * time.Minute,
+}
+
+func TestLockedUntil(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		failures int
+		wait     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{9, 32 * time.Second},
+		{10, 15 * time.Minute},
+		{25, 15 * time.Minute},
+	}
+
+	for _, test := range tests {
+		got := testPolicy.lockedUntil(test.failures, now)
+		if test.wait == 0 {
+			if !got.IsZero() {
+				t.Errorf("%d failures: locked until %v, want no lock", test.failures, got)
+			}
+			continue
+		}
+		if want := now.Add(test.wait); !got.Equal(want) {
+			t.Errorf("%d failures: locked for %v, want %v", test.failures, got.Sub(now), test.wait)
+		}
+	}
+}
+
+func TestLockedUntilBackoffIsCappedByLockout(t *testing.T) {
+	policy := Policy{FreeAttempts: 0, BackoffBase: time.Minute, MaxAttempts: 100, Lockout: 5 * time.Minute}
+	now := time.Now()
+
+	tests := []struct {
+		failures int
+		wait     time.Duration
+	}{
+		{1, time.Minute},
+		{3, 4 * time.Minute},
+		{4, 5 * time.Minute},
+		{60, 5 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if got := policy.lockedUntil(test.failures, now).Sub(now); got != test.wait {
+			t.Errorf("%d failures: locked for %v, want %v", test.failures, got, test.wait)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		attempt  Attempt
+		failures int
+		wait     time.Duration
+	}{
+		{"first failure", Attempt{}, 1, 0},
+		{"first backoff", Attempt{Failures: 3}, 4, time.Second},
+		{"reaching the maximum", Attempt{Failures: 9, LockedUntil: now.Add(-time.Second)}, 10, 15 * time.Minute},
+		{"during the lockout", Attempt{Failures: 10, LockedUntil: now.Add(time.Minute)}, 11, 15 * time.Minute},
+		{"after the lockout", Attempt{Failures: 10, LockedUntil: now.Add(-time.Second)}, 1, 0},
+		{"when the lockout ends", Attempt{Failures: 12, LockedUntil: now}, 1, 0},
+	}
+
+	for _, test := range tests {
+		got := testPolicy.next(test.attempt, now)
+		if got.Failures != test.failures {
+			t.Errorf("%s: %d failures, want %d", test.name, got.Failures, test.failures)
+		}
+		want := time.Time{}
+		if test.wait > 0 {
+			want = now.Add(test.wait)
+		}
+		if !got.LockedUntil.Equal(want) {
+			t.Errorf("%s: locked until %v, want %v", test.name, got.LockedUntil, want)
+		}
+	}
+}
+
+func TestFailAndRetryAfter(t *testing.T) {
+	store := NewMemoryStore()
+	key := EmailKey(" User@Example.com ")
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for i := 0; i < testPolicy.MaxAttempts; i++ {
+		if _, err := Fail(store, key, testPolicy, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wait, err := RetryAfter(store, EmailKey("user@example.com"), now)
+	if err != nil || wait != testPolicy.Lockout {
+		t.Errorf("RetryAfter = %v, %v, want %v", wait, err, testPolicy.Lockout)
+	}
+
+	// Once the lockout is over the key starts from the free attempts again
+	later := now.Add(testPolicy.Lockout)
+	if wait, _ := RetryAfter(store, key, later); wait != 0 {
+		t.Errorf("RetryAfter after the lockout = %v, want 0", wait)
+	}
+	attempt, err := Fail(store, key, testPolicy, later)
+	if err != nil || attempt.Failures != 1 || !attempt.LockedUntil.IsZero() {
+		t.Errorf("Fail after the lockout = %+v, %v, want one failure without lock", attempt, err)
+	}
+
+	if err := store.Reset(key); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, _ := store.Get(key); attempt.Failures != 0 {
+		t.Errorf("%d failures after Reset, want 0", attempt.Failures)
+	}
+}
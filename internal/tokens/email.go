@@ -0,0 +1,72 @@
+package tokens
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+)
+
+const emailVerificationType = "verify_email"
+
+// NewEmailVerificationToken signs the email of the user, so the link stops
+// working once the user changes the email
+func NewEmailVerificationToken(userID uint, email string) (string, error) {
+	ttl := config.GetDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+
+	return sign(jwt.MapClaims{
+		"sub":   userID,
+		"email": email,
+		"typ":   emailVerificationType,
+		"exp":   time.Now().Add(ttl).Unix(),
+	})
+}
+
+// ParseEmailVerificationToken returns the user id and the email of the token
+func ParseEmailVerificationToken(tokenString string) (uint, string, error) {
+	claims, err := parse(tokenString, emailVerificationType)
+	if err != nil {
+		return 0, "", err
+	}
+
+	sub, ok := claims["sub"].(float64)
+	email, ok2 := claims["email"].(string)
+	if !ok || !ok2 {
+		return 0, "", ErrInvalidToken
+	}
+
+	return uint(sub), email, nil
+}
+
+const emailChangeType = "change_email"
+
+// NewEmailChangeToken signs the current and the new email, the link stops
+// working if the email changes in the meantime
+func NewEmailChangeToken(userID uint, currentEmail, newEmail string) (string, error) {
+	ttl := config.GetDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+
+	return sign(jwt.MapClaims{
+		"sub":       userID,
+		"email":     currentEmail,
+		"new_email": newEmail,
+		"typ":       emailChangeType,
+		"exp":       time.Now().Add(ttl).Unix(),
+	})
+}
+
+// ParseEmailChangeToken returns the user id, the current and the new email of the token
+func ParseEmailChangeToken(tokenString string) (uint, string, string, error) {
+	claims, err := parse(tokenString, emailChangeType)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	sub, ok := claims["sub"].(float64)
+	email, ok2 := claims["email"].(string)
+	newEmail, ok3 := claims["new_email"].(string)
+	if !ok || !ok2 || !ok3 {
+		return 0, "", "", ErrInvalidToken
+	}
+
+	return uint(sub), email, newEmail, nil
+}
@@ -0,0 +1,197 @@
+package tokens
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+)
+
+// SecretKeyID is the kid of the HS256 key made from SECRET_KEY
+const SecretKeyID = "secret"
+
+// SigningKey is one of the keys tokens are signed or verified with
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   interface{}
+	Public    interface{}
+	RetiredAt *time.Time
+}
+
+// KeySet holds the active signing key and every key still accepted.
+//
+// Keys are configured with:
+//   - JWT_KEYS_DIR: directory of PEM private keys named <kid>.pem (RSA, EC or Ed25519)
+//   - JWT_ACTIVE_KID: kid used to sign new tokens, "secret" (HS256 SECRET_KEY) by default
+//   - JWT_RETIRED_KEYS: "<kid>=<RFC 3339 time>,..." keys retired at that time
+//   - JWT_KEY_GRACE_PERIOD: how long retired keys are still accepted
+//
+// Keys of the directory that are neither active nor retired are accepted too,
+// so a new key can be published in the JWKS before it starts signing.
+type KeySet struct {
+	Active *SigningKey
+	Keys   map[string]*SigningKey
+	Grace  time.Duration
+}
+
+var (
+	keySet  *KeySet
+	keyErr  error
+	keyOnce sync.Once
+)
+
+// Keys loads the key set once, call it on startup to fail on a bad configuration
+func Keys() (*KeySet, error) {
+	keyOnce.Do(func() {
+		keySet, keyErr = loadKeySet()
+	})
+	return keySet, keyErr
+}
+
+// Find returns the key of the kid if it is still accepted at the moment
+func (set *KeySet) Find(kid string, now time.Time) (*SigningKey, bool) {
+	key, ok := set.Keys[kid]
+	if !ok || set.expired(key, now) {
+		return nil, false
+	}
+	return key, true
+}
+
+// PublicKeys returns the asymmetric keys still accepted, for the JWKS endpoint
+func (set *KeySet) PublicKeys(now time.Time) []*SigningKey {
+	var keys []*SigningKey
+	for _, key := range set.Keys {
+		if _, symmetric := key.Method.(*jwt.SigningMethodHMAC); symmetric || set.expired(key, now) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (set *KeySet) expired(key *SigningKey, now time.Time) bool {
+	return key.RetiredAt != nil && now.After(key.RetiredAt.Add(set.Grace))
+}
+
+func loadKeySet() (*KeySet, error) {
+	set := &KeySet{
+		Keys:  map[string]*SigningKey{},
+		Grace: config.GetDuration("JWT_KEY_GRACE_PERIOD", 24*time.Hour),
+	}
+
+	if secret := os.Getenv("SECRET_KEY"); secret != "" {
+		set.Keys[SecretKeyID] = &SigningKey{
+			ID:      SecretKeyID,
+			Method:  jwt.SigningMethodHS256,
+			Private: []byte(secret),
+			Public:  []byte(secret),
+		}
+	}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			key, err := loadPEMKey(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			set.Keys[key.ID] = key
+		}
+	}
+
+	for _, retired := range strings.Split(os.Getenv("JWT_RETIRED_KEYS"), ",") {
+		if strings.TrimSpace(retired) == "" {
+			continue
+		}
+		kid, at, found := strings.Cut(strings.TrimSpace(retired), "=")
+		retiredAt, err := time.Parse(time.RFC3339, at)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid JWT_RETIRED_KEYS entry: %s", retired)
+		}
+		if key, ok := set.Keys[kid]; ok {
+			key.RetiredAt = &retiredAt
+		}
+	}
+
+	activeID := config.GetEnv("JWT_ACTIVE_KID", SecretKeyID)
+	active, ok := set.Keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q not found, set SECRET_KEY or JWT_KEYS_DIR", activeID)
+	}
+	if active.RetiredAt != nil {
+		return nil, fmt.Errorf("active signing key %q is retired", activeID)
+	}
+	set.Active = active
+
+	return set, nil
+}
+
+func loadPEMKey(file string) (*SigningKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var private interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{
+		ID:      strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+		Private: private,
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.Public = &private.PublicKey
+	case *ecdsa.PrivateKey:
+		switch private.Curve.Params().Name {
+		case "P-256":
+			key.Method = jwt.SigningMethodES256
+		case "P-384":
+			key.Method = jwt.SigningMethodES384
+		case "P-521":
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", private.Curve.Params().Name)
+		}
+		key.Public = &private.PublicKey
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.Public = private.Public().(ed25519.PublicKey)
+	default:
+		return nil, errors.New("unsupported private key type")
+	}
+
+	return key, nil
+}
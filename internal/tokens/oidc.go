@@ -0,0 +1,46 @@
+package tokens
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const oidcStateType = "oidc_state"
+
+// OIDCState is kept in a signed cookie between the redirect to the provider and the callback
+type OIDCState struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	ReturnToken  bool
+}
+
+func NewOIDCState(state OIDCState) (string, error) {
+	return sign(jwt.MapClaims{
+		"typ":           oidcStateType,
+		"state":         state.State,
+		"nonce":         state.Nonce,
+		"code_verifier": state.CodeVerifier,
+		"return_token":  state.ReturnToken,
+		"exp":           time.Now().Add(10 * time.Minute).Unix(),
+	})
+}
+
+func ParseOIDCState(tokenString string) (*OIDCState, error) {
+	claims, err := parse(tokenString, oidcStateType)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &OIDCState{}
+	state.State, _ = claims["state"].(string)
+	state.Nonce, _ = claims["nonce"].(string)
+	state.CodeVerifier, _ = claims["code_verifier"].(string)
+	state.ReturnToken, _ = claims["return_token"].(bool)
+
+	if state.State == "" || state.Nonce == "" || state.CodeVerifier == "" {
+		return nil, ErrInvalidToken
+	}
+	return state, nil
+}
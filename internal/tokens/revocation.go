@@ -0,0 +1,64 @@
+package tokens
+
+import (
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+// Revoke stores the jti until the token expires on its own
+func Revoke(jti string, expiresAt time.Time) error {
+	// Expired entries are useless, clean them up while we are here
+	if err := initializers.DB.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	revoked := models.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	}
+	return initializers.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+}
+
+// IsRevoked reports whether the jti has been revoked
+func IsRevoked(jti string) (bool, error) {
+	var count int64
+	err := initializers.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// RevokeAllForUser rejects every access token issued to the user before
+// the given moment and revokes all of the user's sessions and refresh tokens
+func RevokeAllForUser(userID uint, before time.Time) error {
+	err := initializers.DB.Model(&models.User{}).Where("id = ?", userID).Update("tokens_revoked_at", before).Error
+	if err != nil {
+		return err
+	}
+
+	err = initializers.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND created_at < ?", userID, before).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+
+	return initializers.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND created_at < ?", userID, before).
+		Update("revoked_at", time.Now()).Error
+}
+
+// IssuedBefore reports whether the token claims were issued before the moment.
+// iat has a precision of one second, so tokens issued in the same second
+// as the revocation count as issued before it.
+func IssuedBefore(claims map[string]interface{}, moment *time.Time) bool {
+	if moment == nil {
+		return false
+	}
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return true
+	}
+	return int64(iat) <= moment.Unix()
+}
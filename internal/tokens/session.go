@@ -0,0 +1,102 @@
+package tokens
+
+import (
+	"time"
+
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// StartSession records the login and creates the first refresh token of the session
+func StartSession(userID uint, userAgent, ip string) (*models.Session, string, error) {
+	familyID, err := randomString(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:     userID,
+		FamilyID:   familyID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenAt: now,
+	}
+
+	var refreshToken string
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		refreshToken, err = createRefreshToken(tx, userID, familyID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &session, refreshToken, nil
+}
+
+// FindActiveSession returns the session of the user, unless it has been revoked
+func FindActiveSession(userID, sessionID uint) (*models.Session, error) {
+	var session models.Session
+	err := initializers.DB.Where("user_id = ? AND revoked_at IS NULL", userID).First(&session, sessionID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindSessionByFamily returns the session the refresh token family belongs to
+func FindSessionByFamily(familyID string) (*models.Session, error) {
+	var session models.Session
+	err := initializers.DB.Where("family_id = ? AND revoked_at IS NULL", familyID).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// TouchSession updates the last seen time, at most once a minute
+func TouchSession(session *models.Session, ip string) {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < time.Minute {
+		return
+	}
+
+	initializers.DB.Model(session).Updates(map[string]interface{}{
+		"last_seen_at": now,
+		"ip":           ip,
+	})
+}
+
+// RevokeSession logs the session out, its access tokens are rejected and its
+// refresh tokens revoked
+func RevokeSession(session *models.Session) error {
+	err := initializers.DB.Model(session).Where("revoked_at IS NULL").Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return RevokeFamily(session.FamilyID)
+}
+
+// RevokeOtherSessions logs the user out everywhere but the kept session,
+// keepID 0 revokes every session
+func RevokeOtherSessions(userID, keepID uint) error {
+	var sessions []models.Session
+	err := initializers.DB.Where("user_id = ? AND revoked_at IS NULL AND id <> ?", userID, keepID).Find(&sessions).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		if err := RevokeSession(&sessions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
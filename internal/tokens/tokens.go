@@ -0,0 +1,247 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	AccessCookie  = "Authorization"
+	RefreshCookie = "RefreshToken"
+
+	accessType = "access"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenReused  = errors.New("refresh token reused")
+)
+
+// AccessTTL is the lifetime of the JWT used by RequireAuth
+func AccessTTL() time.Duration {
+	return config.GetDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// RefreshTTL is the lifetime of the opaque refresh token
+func RefreshTTL() time.Duration {
+	return config.GetDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// NewAccessToken signs a short-lived JWT for the user's session
+func NewAccessToken(userID, sessionID uint) (string, error) {
+	jti, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return sign(jwt.MapClaims{
+		"sub": userID,
+		"sid": sessionID,
+		"jti": jti,
+		"typ": accessType,
+		"iat": now.Unix(),
+		"exp": now.Add(AccessTTL()).Unix(),
+	})
+}
+
+// ParseAccessToken validates the signature, the expiration and the type of the JWT
+func ParseAccessToken(tokenString string) (jwt.MapClaims, error) {
+	claims, err := parse(tokenString, accessType)
+	if err != nil {
+		return nil, err
+	}
+
+	// jti and sid are optional for jwt.Parse, but not for us
+	if _, ok := claims["jti"].(string); !ok {
+		return nil, ErrInvalidToken
+	}
+	if _, ok := claims["sid"].(float64); !ok {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// sign signs the claims with the active key of the key set
+func sign(claims jwt.MapClaims) (string, error) {
+	keys, err := Keys()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(keys.Active.Method, claims)
+	token.Header["kid"] = keys.Active.ID
+	return token.SignedString(keys.Active.Private)
+}
+
+// parse validates the signature, the expiration and the type of the JWT
+func parse(tokenString, tokenType string) (jwt.MapClaims, error) {
+	keys, err := Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		// Tokens signed before key rotation existed have no kid
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			kid = SecretKeyID
+		}
+
+		key, ok := keys.Find(kid, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %v", kid)
+		}
+
+		// The algorithm comes with the key, never from the token
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.Public, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	// exp is optional for jwt.Parse, but not for us
+	if _, ok := claims["exp"].(float64); !ok {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one of the same family.
+// Using a token twice revokes the whole family and its session, since it means
+// the token leaked.
+func RotateRefreshToken(raw string) (string, *models.RefreshToken, error) {
+	var newToken string
+	var current models.RefreshToken
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", HashToken(raw)).First(&current).Error; err != nil {
+			return ErrInvalidToken
+		}
+
+		if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+			return ErrInvalidToken
+		}
+
+		// Mark the token as used, only one concurrent request can win this update
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", current.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenReused
+		}
+
+		var err error
+		newToken, err = createRefreshToken(tx, current.UserID, current.FamilyID)
+		return err
+	})
+
+	if errors.Is(err, ErrTokenReused) {
+		if revokeErr := revokeLeakedFamily(current.FamilyID); revokeErr != nil {
+			return "", nil, revokeErr
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	return newToken, &current, nil
+}
+
+// RevokeFamily revokes every token created from the same login
+func RevokeFamily(familyID string) error {
+	return initializers.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeLeakedFamily revokes the session of the family, so the access tokens
+// issued from the leaked refresh token stop working as well
+func revokeLeakedFamily(familyID string) error {
+	session, err := FindSessionByFamily(familyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return RevokeFamily(familyID)
+	}
+	if err != nil {
+		return err
+	}
+	return RevokeSession(session)
+}
+
+// RevokeRefreshToken revokes the family of the given raw token, if it exists
+func RevokeRefreshToken(raw string) error {
+	var token models.RefreshToken
+	if err := initializers.DB.Where("token_hash = ?", HashToken(raw)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return RevokeFamily(token.FamilyID)
+}
+
+// NewOpaqueToken returns a random token for the client and the hash to store
+func NewOpaqueToken() (raw, hash string, err error) {
+	raw, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return raw, HashToken(raw), nil
+}
+
+// HashToken returns the hex encoded sha256 of an opaque token
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func createRefreshToken(db *gorm.DB, userID uint, familyID string) (string, error) {
+	raw, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	token := models.RefreshToken{
+		UserID:    userID,
+		TokenHash: HashToken(raw),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(RefreshTTL()),
+	}
+	if err := db.Create(&token).Error; err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
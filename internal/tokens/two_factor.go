@@ -0,0 +1,54 @@
+package tokens
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/wisnuuakbr/blog-rest-go/config"
+)
+
+const twoFactorType = "2fa_pending"
+
+// NewTwoFactorChallenge is returned by login instead of the access token when
+// the user has TOTP enabled. It only proves that the password was correct.
+func NewTwoFactorChallenge(userID uint, returnToken bool) (string, error) {
+	jti, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := config.GetDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute)
+	return sign(jwt.MapClaims{
+		"sub":          userID,
+		"jti":          jti,
+		"typ":          twoFactorType,
+		"return_token": returnToken,
+		"exp":          time.Now().Add(ttl).Unix(),
+	})
+}
+
+// ParseTwoFactorChallenge validates the challenge and checks it hasn't been used
+func ParseTwoFactorChallenge(tokenString string) (jwt.MapClaims, error) {
+	claims, err := parse(tokenString, twoFactorType)
+	if err != nil {
+		return nil, err
+	}
+
+	jti, ok := claims["jti"].(string)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if _, ok := claims["sub"].(float64); !ok {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := IsRevoked(jti)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
@@ -1,17 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wisnuuakbr/blog-rest-go/api/router"
 	"github.com/wisnuuakbr/blog-rest-go/config"
 	"github.com/wisnuuakbr/blog-rest-go/db/initializers"
+	"github.com/wisnuuakbr/blog-rest-go/internal/scheduler"
+	"github.com/wisnuuakbr/blog-rest-go/internal/tokens"
 )
 
 func init() {
 	config.LoadEnv()
 	initializers.ConnectDB()
+
+	if _, err := tokens.Keys(); err != nil {
+		log.Fatal("Error loading JWT signing keys: ", err)
+	}
 }
 
 func main() {
@@ -19,7 +34,50 @@ func main() {
 	// set releaseMode for production
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
+
+	// ClientIP only reads X-Forwarded-For from these proxies, the login
+	// throttle relies on it
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("Error setting trusted proxies: ", err)
+	}
 	router.GetRouter(r)
 
-	r.Run()
-}
\ No newline at end of file
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Background jobs: scheduled posts, account deletion
+	jobs := scheduler.Default()
+	jobs.Start(ctx)
+
+	server := &http.Server{
+		Addr:    ":" + config.GetEnv("PORT", "8080"),
+		Handler: r,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Error starting server: ", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+
+	// Finish the running requests and jobs before exiting
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.GetDuration("SHUTDOWN_TIMEOUT", 10*time.Second))
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Error shutting down server:", err)
+	}
+	jobs.Wait()
+}
+
+// trustedProxies reads the comma-separated TRUSTED_PROXIES, none by default
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
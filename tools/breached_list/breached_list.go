@@ -0,0 +1,47 @@
+// Builds the breached password list used by PASSWORD_BREACHED_FILE from a
+// plain text list, one password per line. Only the hashes end up in the output.
+//
+//	go run tools/breached_list/breached_list.go < passwords.txt > data/breached_passwords.txt
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/wisnuuakbr/blog-rest-go/internal/passwords"
+)
+
+func main() {
+	seen := map[string]bool{}
+	var lines []string
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		password := scanner.Text()
+		if password == "" {
+			continue
+		}
+
+		line := passwords.HashPrefix(password)
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal("Failed to read the passwords", err)
+	}
+
+	sort.Strings(lines)
+
+	writer := bufio.NewWriter(os.Stdout)
+	defer writer.Flush()
+
+	fmt.Fprintln(writer, "# SHA-1 PREFIX:SUFFIX of breached passwords")
+	for _, line := range lines {
+		fmt.Fprintln(writer, line)
+	}
+}